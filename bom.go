@@ -0,0 +1,116 @@
+package fwencoder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// ErrTruncatedUTF16 is returned when UTF-16 encoded input ends in the middle of a code unit.
+var ErrTruncatedUTF16 = errors.New("truncated UTF-16 data")
+
+// newBOMReader detects a byte order mark at the beginning of the input and strips it.
+// UTF-16 input is transcoded to UTF-8. Input without a BOM is returned as is.
+func newBOMReader(reader io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(reader)
+	prefix, err := br.Peek(len(utf8BOM))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(prefix, utf8BOM):
+		_, err = br.Discard(len(utf8BOM))
+		return br, err
+	case bytes.HasPrefix(prefix, utf16LEBOM):
+		_, err = br.Discard(len(utf16LEBOM))
+		return &utf16Reader{reader: br, order: binary.LittleEndian}, err
+	case bytes.HasPrefix(prefix, utf16BEBOM):
+		_, err = br.Discard(len(utf16BEBOM))
+		return &utf16Reader{reader: br, order: binary.BigEndian}, err
+	}
+	return br, nil
+}
+
+// utf16Reader transcodes UTF-16 input into UTF-8.
+type utf16Reader struct {
+	reader     io.Reader
+	order      binary.ByteOrder
+	buf        []byte // transcoded data which has not been read yet
+	pending    rune   // code unit read ahead while looking for a low surrogate
+	hasPending bool
+	err        error
+	unit       [2]byte
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(u.buf) == 0 {
+			if u.err != nil {
+				break
+			}
+			var r rune
+			r, u.err = u.readRune()
+			if u.err != nil {
+				break
+			}
+			u.buf = utf8.AppendRune(u.buf[:0], r)
+		}
+		copied := copy(p[n:], u.buf)
+		u.buf = u.buf[copied:]
+		n += copied
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, u.err
+}
+
+func (u *utf16Reader) readRune() (rune, error) {
+	r1, err := u.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(r1) {
+		return r1, nil
+	}
+
+	r2, err := u.readUnit()
+	if errors.Is(err, io.EOF) {
+		return utf8.RuneError, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if r := utf16.DecodeRune(r1, r2); r != utf8.RuneError {
+		return r, nil
+	}
+	// r2 is not a valid low surrogate, so it starts the next rune
+	u.pending, u.hasPending = r2, true
+	return utf8.RuneError, nil
+}
+
+func (u *utf16Reader) readUnit() (rune, error) {
+	if u.hasPending {
+		u.hasPending = false
+		return u.pending, nil
+	}
+	if _, err := io.ReadFull(u.reader, u.unit[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, ErrTruncatedUTF16
+		}
+		return 0, err
+	}
+	return rune(u.order.Uint16(u.unit[:])), nil
+}
@@ -0,0 +1,128 @@
+// Command fwdoc generates record layout documentation for fixed width files.
+//
+// The layout is read from a Go struct with fw tags, a layout JSON file or a vendor spec table:
+//
+//	fwdoc -type Person -dir ./models -format markdown -o PERSON.md
+//	fwdoc -layout person.json -format html
+//	fwdoc -spec vendor.csv -format csv
+//
+// It's go:generate friendly, e.g. //go:generate go run github.com/o1egl/fwencoder/cmd/fwdoc -type Person -o person.md
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/o1egl/fwencoder"
+	"github.com/o1egl/fwencoder/internal/structsrc"
+)
+
+type config struct {
+	typeName string
+	dir      string
+	layout   string
+	spec     string
+	format   string
+	base     int
+	output   string
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.typeName, "type", "", "name of the struct type to document")
+	flag.StringVar(&cfg.dir, "dir", ".", "directory of the Go package declaring the struct type")
+	flag.StringVar(&cfg.layout, "layout", "", "layout JSON file to document")
+	flag.StringVar(&cfg.spec, "spec", "", "vendor spec file to document, .json or .csv")
+	flag.StringVar(&cfg.format, "format", "markdown", "output format: markdown, html or csv")
+	flag.IntVar(&cfg.base, "base", 1, "position base of the documented positions, 0 or 1")
+	flag.StringVar(&cfg.output, "o", "", "output file, stdout by default")
+	flag.Parse()
+
+	if err := run(&cfg, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "fwdoc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config, stdout io.Writer) error {
+	opts, err := docOptions(cfg)
+	if err != nil {
+		return err
+	}
+	source, err := loadSource(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.output == "" {
+		return fwencoder.WriteLayoutDoc(stdout, source, opts)
+	}
+	f, err := os.Create(cfg.output)
+	if err != nil {
+		return err
+	}
+	if err := fwencoder.WriteLayoutDoc(f, source, opts); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func docOptions(cfg *config) (fwencoder.DocOptions, error) {
+	var opts fwencoder.DocOptions
+	switch strings.ToLower(cfg.format) {
+	case "markdown", "md":
+		opts.Format = fwencoder.DocMarkdown
+	case "html":
+		opts.Format = fwencoder.DocHTML
+	case "csv":
+		opts.Format = fwencoder.DocCSV
+	default:
+		return opts, fmt.Errorf("unknown format %q", cfg.format)
+	}
+	switch cfg.base {
+	case 0:
+		opts.Base = fwencoder.BaseZero
+	case 1:
+		opts.Base = fwencoder.BaseOne
+	default:
+		return opts, fmt.Errorf("invalid position base %d", cfg.base)
+	}
+	return opts, nil
+}
+
+// loadSource returns the layout or a pointer to a zero struct which WriteLayoutDoc documents.
+func loadSource(cfg *config) (any, error) {
+	switch {
+	case cfg.typeName != "":
+		t, err := structsrc.Load(cfg.dir, cfg.typeName)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.New(t).Interface(), nil
+	case cfg.layout != "":
+		f, err := os.Open(cfg.layout)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return fwencoder.LoadLayoutJSON(f)
+	case cfg.spec != "":
+		f, err := os.Open(cfg.spec)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if strings.EqualFold(filepath.Ext(cfg.spec), ".json") {
+			return fwencoder.LoadSpecJSON(f, fwencoder.SpecOptions{AllowGaps: true})
+		}
+		return fwencoder.LoadSpecCSV(f, fwencoder.SpecOptions{AllowGaps: true})
+	}
+	return nil, errors.New("one of -type, -layout or -spec is required")
+}
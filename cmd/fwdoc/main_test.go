@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `package sample
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Person struct {
+	Name    string        ` + "`" + `fw:",width=8" description:"Full name"` + "`" + `
+	Balance float64       ` + "`" + `fw:",width=10,align=right"` + "`" + `
+	Paid    sql.NullTime  ` + "`" + `fw:",width=8" format:"20060102"` + "`" + `
+	Timeout time.Duration ` + "`" + `fw:",width=6"` + "`" + `
+}
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "person.go"), []byte(source), 0o600))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run(&config{typeName: "Person", dir: dir, format: "csv", base: 1}, &stdout))
+	expected := "Name,Start,End,Width,Type,Format,Align,Pad,Description\n" +
+		"Name,1,8,8,string,,left,,Full name\n" +
+		"Balance,9,18,10,float,,right,,\n" +
+		"Paid,19,26,8,time,20060102,left,,\n" +
+		"Timeout,27,32,6,int,,left,,\n"
+	assert.Equal(t, expected, stdout.String())
+
+	spec := filepath.Join(dir, "spec.csv")
+	require.NoError(t, os.WriteFile(spec, []byte(expected), 0o600))
+	output := filepath.Join(dir, "doc.csv")
+	require.NoError(t, run(&config{spec: spec, format: "csv", base: 1, output: output}, &stdout))
+	b, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(b))
+
+	require.EqualError(t, run(&config{format: "csv", base: 1}, &stdout), "one of -type, -layout or -spec is required")
+	require.EqualError(t, run(&config{typeName: "Person", format: "pdf"}, &stdout), `unknown format "pdf"`)
+}
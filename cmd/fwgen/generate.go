@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/o1egl/fwencoder"
+)
+
+// field is a struct field of the generated type.
+type field struct {
+	name string
+	typ  string
+	tags []string
+}
+
+// compactTimeFormats look like numbers, they are tried before the numeric types when inferring column types.
+var compactTimeFormats = []string{
+	"20060102150405",
+	"20060102",
+}
+
+// timeFormats are tried in order when inferring time columns from sample data.
+var timeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+}
+
+// initialisms keep their case in field names, e.g. CUSTOMER_ID becomes CustomerID.
+var initialisms = map[string]bool{
+	"ID": true, "URL": true, "UUID": true, "API": true, "HTTP": true, "IP": true, "SSN": true, "SKU": true,
+}
+
+// fieldsFromSample infers the field types from the decoded sample records. A column becomes an integer, float,
+// boolean or time field only when every cell parses as such, empty cells and numbers with leading zeros,
+// which are usually codes, keep the column a string.
+func fieldsFromSample(columns []string, records []fwencoder.Record) []field {
+	names := newFieldNames()
+	fields := make([]field, len(columns))
+	for i, column := range columns {
+		values := make([]string, len(records))
+		for j, r := range records {
+			values[j] = r.String(column)
+		}
+		typ, timeFormat := inferType(values)
+		f := field{name: names.get(column), typ: typ, tags: []string{tag("column", column)}}
+		if timeFormat != "" && timeFormat != time.RFC3339 {
+			f.tags = append(f.tags, tag("format", timeFormat))
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+func inferType(values []string) (typ, timeFormat string) {
+	if len(values) == 0 {
+		return "string", ""
+	}
+	for _, v := range values {
+		if v == "" {
+			return "string", ""
+		}
+	}
+	for _, layout := range compactTimeFormats {
+		if all(values, func(v string) bool { return isTime(v, layout) }) {
+			return "time.Time", layout
+		}
+	}
+	switch {
+	case all(values, isInt):
+		return "int64", ""
+	case all(values, isFloat):
+		return "float64", ""
+	case all(values, isBool):
+		return "bool", ""
+	}
+	for _, layout := range timeFormats {
+		if all(values, func(v string) bool { return isTime(v, layout) }) {
+			return "time.Time", layout
+		}
+	}
+	return "string", ""
+}
+
+func all(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if !fn(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInt(v string) bool {
+	if hasLeadingZero(v) {
+		return false
+	}
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+func isFloat(v string) bool {
+	if hasLeadingZero(v) && !strings.HasPrefix(strings.TrimLeft(v, "+-"), "0.") {
+		return false
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil && !strings.ContainsAny(v, "xXpPnN")
+}
+
+func hasLeadingZero(v string) bool {
+	v = strings.TrimLeft(v, "+-")
+	return len(v) > 1 && v[0] == '0'
+}
+
+func isBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "false":
+		return true
+	}
+	return false
+}
+
+func isTime(v, layout string) bool {
+	if len(v) != len(layout) && layout != time.RFC3339 {
+		return false
+	}
+	_, err := time.Parse(layout, v)
+	return err == nil
+}
+
+var columnGoTypes = map[fwencoder.ColumnType]string{
+	fwencoder.TypeString: "string",
+	fwencoder.TypeInt:    "int64",
+	fwencoder.TypeUint:   "uint64",
+	fwencoder.TypeFloat:  "float64",
+	fwencoder.TypeBool:   "bool",
+	fwencoder.TypeTime:   "time.Time",
+	fwencoder.TypeJSON:   "json.RawMessage",
+}
+
+// fieldsFromLayout maps layout columns to fields with fw tags keeping the positions, alignment and padding.
+// Names which can't be written in the fw tag, e.g. "Amount, USD", are set by the column tag.
+func fieldsFromLayout(layout *fwencoder.Layout) ([]field, error) {
+	names := newFieldNames()
+	fields := make([]field, len(layout.Columns))
+	for i := range layout.Columns {
+		c := &layout.Columns[i]
+		if c.Name == "-" {
+			return nil, fmt.Errorf("column %q: the name ignores the field in struct tags", c.Name)
+		}
+		if c.Pad == "," {
+			return nil, fmt.Errorf("column %s: pad %q can't be written in the fw tag", c.Name, c.Pad)
+		}
+		fwName, columnTag := c.Name, false
+		if strings.ContainsAny(c.Name, ",=") {
+			fwName, columnTag = "", true
+		}
+		fw := fmt.Sprintf("%s,start=%d,width=%d", fwName, c.Start, c.Width)
+		if c.Align == fwencoder.AlignRight {
+			fw += ",align=right"
+		}
+		if c.Pad != "" && c.Pad != " " {
+			fw += ",pad=" + c.Pad
+		}
+		typ, ok := columnGoTypes[c.Type]
+		if !ok {
+			typ = "string"
+		}
+		f := field{name: names.get(c.Name), typ: typ, tags: []string{tag("fw", fw)}}
+		if columnTag {
+			f.tags = append(f.tags, tag("column", c.Name))
+		}
+		if c.Format != "" && c.Type == fwencoder.TypeTime {
+			f.tags = append(f.tags, tag("format", c.Format))
+		}
+		if c.Description != "" {
+			f.tags = append(f.tags, tag("description", c.Description))
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+func tag(key, value string) string {
+	return key + ":" + strconv.Quote(value)
+}
+
+// fieldNames converts column names to unique exported Go identifiers.
+type fieldNames map[string]bool
+
+func newFieldNames() fieldNames {
+	return make(fieldNames)
+}
+
+func (n fieldNames) get(column string) string {
+	name := goName(column)
+	unique := name
+	for i := 2; n[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	n[unique] = true
+	return unique
+}
+
+func goName(column string) string {
+	parts := strings.FieldsFunc(column, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sb strings.Builder
+	for _, part := range parts {
+		upper := strings.ToUpper(part)
+		switch {
+		case initialisms[upper]:
+			sb.WriteString(upper)
+		case part == upper:
+			runes := []rune(strings.ToLower(part))
+			runes[0] = unicode.ToUpper(runes[0])
+			sb.WriteString(string(runes))
+		default:
+			runes := []rune(part)
+			runes[0] = unicode.ToUpper(runes[0])
+			sb.WriteString(string(runes))
+		}
+	}
+	name := sb.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Column" + name
+	}
+	return name
+}
+
+// render returns the formatted source of a file declaring the struct type.
+func render(pkg, typeName, comment string, fields []field) ([]byte, error) {
+	imports := make(map[string]bool)
+	for _, f := range fields {
+		switch {
+		case strings.HasPrefix(f.typ, "time."):
+			imports["time"] = true
+		case strings.HasPrefix(f.typ, "json."):
+			imports["encoding/json"] = true
+		}
+	}
+	sortedImports := make([]string, 0, len(imports))
+	for imp := range imports {
+		sortedImports = append(sortedImports, imp)
+	}
+	sort.Strings(sortedImports)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by fwgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if len(sortedImports) == 1 {
+		fmt.Fprintf(&buf, "import %q\n\n", sortedImports[0])
+	} else if len(sortedImports) > 1 {
+		buf.WriteString("import (\n")
+		for _, imp := range sortedImports {
+			fmt.Fprintf(&buf, "%q\n", imp)
+		}
+		buf.WriteString(")\n\n")
+	}
+	fmt.Fprintf(&buf, "// %s %s\n", typeName, comment)
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	for _, f := range fields {
+		tags := strings.Join(f.tags, " ")
+		if strings.Contains(tags, "`") {
+			return nil, fmt.Errorf("field %s: tags can't contain backquotes", f.name)
+		}
+		fmt.Fprintf(&buf, "%s %s `%s`\n", f.name, f.typ, tags)
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
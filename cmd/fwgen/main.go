@@ -0,0 +1,244 @@
+// Command fwgen generates Go structs for fixed width files.
+//
+// The struct is generated either from a sample file, where the columns are taken from the header line and
+// the field types are inferred from the data, or from a layout JSON file or a vendor spec table:
+//
+//	fwgen -type Person -sample people.txt -o person.go
+//	fwgen -type Person -layout person.json
+//	fwgen -type Person -spec vendor.csv
+//
+// It's go:generate friendly, the package name defaults to $GOPACKAGE:
+//
+//	//go:generate go run github.com/o1egl/fwencoder/cmd/fwgen -type Person -sample testdata/people.txt -o person_gen.go
+//
+// With -methods it generates reflection-free MarshalFixedWidth and UnmarshalFixedWidth methods
+// for existing struct types of the package in -dir instead. The encoder and decoder use them automatically:
+//
+//	//go:generate go run github.com/o1egl/fwencoder/cmd/fwgen -methods -type Person,Address -o fw_gen.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/o1egl/fwencoder"
+)
+
+type config struct {
+	typeName string
+	pkg      string
+	sample   string
+	lines    int
+	layout   string
+	spec     string
+	output   string
+	methods  bool
+	dir      string
+}
+
+func main() {
+	cfg := config{pkg: os.Getenv("GOPACKAGE")}
+	if cfg.pkg == "" {
+		cfg.pkg = "main"
+	}
+	flag.StringVar(&cfg.typeName, "type", "", "name of the generated struct type")
+	flag.StringVar(&cfg.pkg, "package", cfg.pkg, "package name of the generated file, $GOPACKAGE by default")
+	flag.StringVar(&cfg.sample, "sample", "", "sample fixed width file with a header line")
+	flag.IntVar(&cfg.lines, "lines", 0, "number of sample rows used for type inference, 0 means all rows")
+	flag.StringVar(&cfg.layout, "layout", "", "layout JSON file")
+	flag.StringVar(&cfg.spec, "spec", "", "vendor spec file, .json or .csv")
+	flag.StringVar(&cfg.output, "o", "", "output file, stdout by default")
+	flag.BoolVar(&cfg.methods, "methods", false, "generate marshaling methods for the comma separated -type list")
+	flag.StringVar(&cfg.dir, "dir", ".", "directory of the package declaring the -methods types")
+	flag.Parse()
+
+	if err := run(&cfg, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "fwgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config, stdout io.Writer) error {
+	if cfg.typeName == "" {
+		return errors.New("-type is required")
+	}
+	src, err := generate(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.output == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(cfg.output, src, 0o644) //nolint:gosec // generated source is world readable
+}
+
+func generate(cfg *config) ([]byte, error) {
+	if cfg.methods {
+		return generateMethods(cfg.dir, strings.Split(cfg.typeName, ","))
+	}
+	fields, source, err := loadFields(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return render(cfg.pkg, cfg.typeName, "is a row of "+filepath.Base(source)+".", fields)
+}
+
+func loadFields(cfg *config) (fields []field, source string, err error) {
+	switch {
+	case cfg.sample != "":
+		fields, err = loadSample(cfg.sample, cfg.lines)
+		return fields, cfg.sample, err
+	case cfg.layout != "":
+		layout, err := loadLayout(cfg.layout, func(r io.Reader) (*fwencoder.Layout, error) {
+			return fwencoder.LoadLayoutJSON(r)
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		fields, err = fieldsFromLayout(layout)
+		return fields, cfg.layout, err
+	case cfg.spec != "":
+		load := func(r io.Reader) (*fwencoder.Layout, error) {
+			if strings.EqualFold(filepath.Ext(cfg.spec), ".json") {
+				return fwencoder.LoadSpecJSON(r, fwencoder.SpecOptions{AllowGaps: true})
+			}
+			return fwencoder.LoadSpecCSV(r, fwencoder.SpecOptions{AllowGaps: true})
+		}
+		layout, err := loadLayout(cfg.spec, load)
+		if err != nil {
+			return nil, "", err
+		}
+		fields, err = fieldsFromLayout(layout)
+		return fields, cfg.spec, err
+	}
+	return nil, "", errors.New("one of -sample, -layout or -spec is required")
+}
+
+func loadLayout(path string, load func(io.Reader) (*fwencoder.Layout, error)) (*fwencoder.Layout, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return load(f)
+}
+
+func loadSample(path string, lines int) ([]field, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	layout := sampleLayout(data)
+	if len(layout.Columns) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, fwencoder.ErrHeaderNotFound)
+	}
+	var records []fwencoder.Record
+	if err := fwencoder.Unmarshal(data, &records,
+		fwencoder.WithLayout(layout), fwencoder.WithSkipBlankLines(), fwencoder.WithPadShortLines()); err != nil {
+		return nil, err
+	}
+	if lines > 0 && len(records) > lines {
+		records = records[:lines]
+	}
+	return fieldsFromSample(layout.Names(), records), nil
+}
+
+// sampleLayout derives the columns of the sample from its data. Positions blank in the header line and in every row
+// separate the columns, so the header names may contain spaces. A header word without values below it joins
+// the neighbouring column with values if a single blank separates them, values outside of the header names
+// belong to the column on the left.
+func sampleLayout(data []byte) *fwencoder.Layout {
+	var header []rune
+	var used, values []bool
+	for _, line := range strings.Split(string(data), "\n") {
+		runes := []rune(strings.TrimRightFunc(line, unicode.IsSpace))
+		if len(runes) == 0 {
+			continue
+		}
+		for len(used) < len(runes) {
+			used, values = append(used, false), append(values, false)
+		}
+		for i, r := range runes {
+			blank := unicode.IsSpace(r)
+			used[i] = used[i] || !blank
+			values[i] = values[i] || (header != nil && !blank)
+		}
+		if header == nil {
+			header = runes
+		}
+	}
+
+	segments := joinHeaderWords(sampleSegments(used, values))
+	layout := &fwencoder.Layout{}
+	for _, seg := range segments {
+		if name := strings.TrimSpace(string(header[min(seg.start, len(header)):min(seg.end, len(header))])); name != "" {
+			layout.Columns = append(layout.Columns, fwencoder.Column{Name: name, Start: seg.start})
+		}
+	}
+	for i := range layout.Columns {
+		end := len(used)
+		if i+1 < len(layout.Columns) {
+			end = layout.Columns[i+1].Start
+		}
+		layout.Columns[i].Width = end - layout.Columns[i].Start
+	}
+	if len(layout.Columns) > 0 {
+		layout.Columns[0].Width += layout.Columns[0].Start
+		layout.Columns[0].Start = 0
+	}
+	return layout
+}
+
+// sampleSegment is a run of positions used by the header or the rows of a sample.
+type sampleSegment struct {
+	start, end int
+	values     bool // the rows have values in the segment
+}
+
+func sampleSegments(used, values []bool) []sampleSegment {
+	var segments []sampleSegment
+	for start := 0; start < len(used); start++ {
+		if !used[start] {
+			continue
+		}
+		seg := sampleSegment{start: start, end: start}
+		for ; seg.end < len(used) && used[seg.end]; seg.end++ {
+			seg.values = seg.values || values[seg.end]
+		}
+		segments = append(segments, seg)
+		start = seg.end
+	}
+	return segments
+}
+
+// joinHeaderWords joins the segments without values to the neighbouring segments with values separated
+// by a single blank, the left neighbour first.
+func joinHeaderWords(segments []sampleSegment) []sampleSegment {
+	for joined := true; joined; {
+		joined = false
+		for i := 0; i < len(segments); i++ {
+			seg := segments[i]
+			switch {
+			case seg.values:
+				continue
+			case i > 0 && segments[i-1].values && seg.start-segments[i-1].end == 1:
+				segments[i-1].end = seg.end
+			case i+1 < len(segments) && segments[i+1].values && segments[i+1].start-seg.end == 1:
+				segments[i+1].start = seg.start
+			default:
+				continue
+			}
+			segments = slices.Delete(segments, i, i+1)
+			joined = true
+		}
+	}
+	return segments
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/o1egl/fwencoder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_Sample(t *testing.T) {
+	sample := "CUSTOMER_ID NAME    ZIP   AMOUNT  ACTIVE BIRTHDAY   first-name\n" +
+		"1           John    01234 12.5    true   1987-01-01 Johnny\n" +
+		"2           Jane    98765 -3      false  1965-12-03\n"
+	path := filepath.Join(t.TempDir(), "customers.txt")
+	require.NoError(t, os.WriteFile(path, []byte(sample), 0o600))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run(&config{typeName: "Customer", pkg: "models", sample: path}, &stdout))
+	assert.Equal(t, "// Code generated by fwgen. DO NOT EDIT.\n"+
+		"\n"+
+		"package models\n"+
+		"\n"+
+		"import \"time\"\n"+
+		"\n"+
+		"// Customer is a row of customers.txt.\n"+
+		"type Customer struct {\n"+
+		"\tCustomerID int64     `column:\"CUSTOMER_ID\"`\n"+
+		"\tName       string    `column:\"NAME\"`\n"+
+		"\tZip        string    `column:\"ZIP\"`\n"+
+		"\tAmount     float64   `column:\"AMOUNT\"`\n"+
+		"\tActive     bool      `column:\"ACTIVE\"`\n"+
+		"\tBirthday   time.Time `column:\"BIRTHDAY\" format:\"2006-01-02\"`\n"+
+		"\tFirstName  string    `column:\"first-name\"`\n"+
+		"}\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run(&config{typeName: "Customer", pkg: "models", sample: path, lines: 1}, &stdout))
+	assert.Contains(t, stdout.String(), "\tFirstName  string    `column:\"first-name\"`\n")
+	assert.Contains(t, stdout.String(), "\tAmount     float64   `column:\"AMOUNT\"`\n")
+
+	require.NoError(t, os.WriteFile(path, []byte("A B\n"), 0o600))
+	stdout.Reset()
+	require.NoError(t, run(&config{typeName: "Customer", pkg: "models", sample: path}, &stdout))
+	assert.Contains(t, stdout.String(), "\tA string `column:\"A\"`\n\tB string `column:\"B\"`\n")
+}
+
+func TestRun_SampleColumnsWithSpaces(t *testing.T) {
+	sample := "ID Customer Name   Credit Limit Open Since\n" +
+		"1  Jonathan Doe            1500 2019\n" +
+		"2  Jane Smithson             20 2021\n"
+	path := filepath.Join(t.TempDir(), "customers.txt")
+	require.NoError(t, os.WriteFile(path, []byte(sample), 0o600))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run(&config{typeName: "Customer", pkg: "models", sample: path}, &stdout))
+	assert.Contains(t, stdout.String(), "type Customer struct {\n"+
+		"\tID           int64  `column:\"ID\"`\n"+
+		"\tCustomerName string `column:\"Customer Name\"`\n"+
+		"\tCreditLimit  int64  `column:\"Credit Limit\"`\n"+
+		"\tOpenSince    int64  `column:\"Open Since\"`\n"+
+		"}\n")
+}
+
+func TestRun_SampleCompactDates(t *testing.T) {
+	sample := "Name            Address               Postcode Phone          Credit Limit Birthday\n" +
+		"Evan Whitehouse V4560 Camel Back Road 3122     (918) 605-5383    1000000.5 19870101\n" +
+		"Chuck Norris    P.O. Box 872          77868    (713) 868-6003     10909300 19651203\n"
+	path := filepath.Join(t.TempDir(), "customers.txt")
+	require.NoError(t, os.WriteFile(path, []byte(sample), 0o600))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run(&config{typeName: "Customer", pkg: "models", sample: path}, &stdout))
+	assert.Contains(t, stdout.String(), "type Customer struct {\n"+
+		"\tName        string    `column:\"Name\"`\n"+
+		"\tAddress     string    `column:\"Address\"`\n"+
+		"\tPostcode    int64     `column:\"Postcode\"`\n"+
+		"\tPhone       string    `column:\"Phone\"`\n"+
+		"\tCreditLimit float64   `column:\"Credit Limit\"`\n"+
+		"\tBirthday    time.Time `column:\"Birthday\" format:\"20060102\"`\n"+
+		"}\n")
+
+	require.NoError(t, os.WriteFile(path, []byte("ID Stamp\n1  20240102150405\n42 20240102153000\n"), 0o600))
+	stdout.Reset()
+	require.NoError(t, run(&config{typeName: "Event", pkg: "models", sample: path}, &stdout))
+	assert.Contains(t, stdout.String(), "\tID    int64     `column:\"ID\"`\n"+
+		"\tStamp time.Time `column:\"Stamp\" format:\"20060102150405\"`\n")
+}
+
+func TestRun_Spec(t *testing.T) {
+	spec := "Field Name,Start,Length,Type,Format,Justify,Padding,Description\n" +
+		"Name,1,16,AN,,,,Full name\n" +
+		"Amount,17,10,Decimal,,right,zeros,\n" +
+		"Birthday,27,8,Date,YYYYMMDD,,,\n" +
+		"Payload,40,20,json,,,,\n"
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vendor.csv")
+	require.NoError(t, os.WriteFile(path, []byte(spec), 0o600))
+	output := filepath.Join(dir, "record_gen.go")
+
+	require.NoError(t, run(&config{typeName: "Record", pkg: "vendor", spec: path, output: output}, nil))
+	b, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "// Code generated by fwgen. DO NOT EDIT.\n"+
+		"\n"+
+		"package vendor\n"+
+		"\n"+
+		"import (\n"+
+		"\t\"encoding/json\"\n"+
+		"\t\"time\"\n"+
+		")\n"+
+		"\n"+
+		"// Record is a row of vendor.csv.\n"+
+		"type Record struct {\n"+
+		"\tName     string          `fw:\"Name,start=0,width=16\" description:\"Full name\"`\n"+
+		"\tAmount   float64         `fw:\"Amount,start=16,width=10,align=right,pad=0\"`\n"+
+		"\tBirthday time.Time       `fw:\"Birthday,start=26,width=8\" format:\"20060102\"`\n"+
+		"\tPayload  json.RawMessage `fw:\"Payload,start=39,width=20\"`\n"+
+		"}\n", string(b))
+}
+
+func TestFieldsFromLayout(t *testing.T) {
+	layout := &fwencoder.Layout{Columns: []fwencoder.Column{
+		{Name: "Amount, USD", Start: 0, Width: 10, Type: fwencoder.TypeFloat},
+		{Name: "Rate=%", Start: 10, Width: 5},
+	}}
+	fields, err := fieldsFromLayout(layout)
+	require.NoError(t, err)
+	assert.Equal(t, []field{
+		{name: "AmountUsd", typ: "float64", tags: []string{`fw:",start=0,width=10"`, `column:"Amount, USD"`}},
+		{name: "Rate", typ: "string", tags: []string{`fw:",start=10,width=5"`, `column:"Rate=%"`}},
+	}, fields)
+
+	// the generated tags name the columns of the layout
+	type Generated struct {
+		AmountUsd float64 `fw:",start=0,width=10" column:"Amount, USD"`
+		Rate      string  `fw:",start=10,width=5" column:"Rate=%"`
+	}
+	generated, err := fwencoder.LayoutOf(Generated{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Amount, USD", "Rate=%"}, generated.Names())
+
+	_, err = fieldsFromLayout(&fwencoder.Layout{Columns: []fwencoder.Column{{Name: "-", Width: 1}}})
+	require.EqualError(t, err, `column "-": the name ignores the field in struct tags`)
+	_, err = fieldsFromLayout(&fwencoder.Layout{Columns: []fwencoder.Column{{Name: "Code", Width: 4, Pad: ","}}})
+	require.EqualError(t, err, `column Code: pad "," can't be written in the fw tag`)
+}
+
+func TestRun_Errors(t *testing.T) {
+	require.EqualError(t, run(&config{}, nil), "-type is required")
+	require.EqualError(t, run(&config{typeName: "T"}, nil), "one of -sample, -layout or -spec is required")
+}
+
+func TestGoName(t *testing.T) {
+	for column, expected := range map[string]string{
+		"CUSTOMER_ID":  "CustomerID",
+		"firstName":    "FirstName",
+		"first name":   "FirstName",
+		"2nd address":  "Column2ndAddress",
+		"%":            "Column",
+		"order.amount": "OrderAmount",
+	} {
+		assert.Equal(t, expected, goName(column), column)
+	}
+
+	names := newFieldNames()
+	assert.Equal(t, "Name", names.get("NAME"))
+	assert.Equal(t, "Name2", names.get("name"))
+}
+
+func TestRun_Methods(t *testing.T) {
+	// the generated methods of the test types must be up to date
+	dir := filepath.Join("..", "..", "internal", "codegentest")
+	var stdout bytes.Buffer
+	require.NoError(t, run(&config{typeName: "Payment", methods: true, dir: dir}, &stdout))
+	expected, err := os.ReadFile(filepath.Join(dir, "payment_fw.go"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), stdout.String())
+
+	src := "package sample\n\ntype Item struct {\n\tName string\n\tValue any\n}\n\ntype Code int\n"
+	dir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sample.go"), []byte(src), 0o600))
+	require.EqualError(t, run(&config{typeName: "Item", methods: true, dir: dir}, &stdout),
+		"Item: field Value: interface fields aren't supported")
+	require.EqualError(t, run(&config{typeName: "Code", methods: true, dir: dir}, &stdout), "Code is not a struct type")
+	require.EqualError(t, run(&config{typeName: "Other", methods: true, dir: dir}, &stdout), "type Other not found in "+dir)
+}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/o1egl/fwencoder/internal/structsrc"
+)
+
+// cellKind is the representation of a field value in a cell, it follows the reflective encoder and decoder.
+type cellKind int
+
+const (
+	cellJSON cellKind = iota
+	cellInt
+	cellUint
+	cellFloat
+	cellString
+	cellBool
+	cellTime
+)
+
+// overflowChecks are the conditions under which a parsed number doesn't fit into a field of the basic kind.
+var overflowChecks = map[types.BasicKind]string{
+	types.Int8:    "x < math.MinInt8 || x > math.MaxInt8",
+	types.Int16:   "x < math.MinInt16 || x > math.MaxInt16",
+	types.Int32:   "x < math.MinInt32 || x > math.MaxInt32",
+	types.Uint8:   "x > math.MaxUint8",
+	types.Uint16:  "x > math.MaxUint16",
+	types.Uint32:  "x > math.MaxUint32",
+	types.Float32: "a := math.Abs(x); a > math.MaxFloat32 && a <= math.MaxFloat64",
+}
+
+// methodsGenerator renders FixedWidthMarshaler and FixedWidthUnmarshaler implementations for struct types
+// of a type checked package. The generated code doesn't depend on the fwencoder package.
+type methodsGenerator struct {
+	pkg     *types.Package
+	imports map[string]string // import path -> package name
+	buf     bytes.Buffer
+}
+
+// generateMethods type checks the package in dir and returns the formatted source of a file with
+// MarshalFixedWidth and UnmarshalFixedWidth methods of the named struct types.
+func generateMethods(dir string, typeNames []string) ([]byte, error) {
+	pkg, err := structsrc.LoadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	g := &methodsGenerator{pkg: pkg, imports: make(map[string]string)}
+	for _, name := range typeNames {
+		obj := pkg.Scope().Lookup(name)
+		if obj == nil {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		s, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a struct type", name)
+		}
+		if err := g.generateType(name, s); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by fwgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg.Name())
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	src.WriteString("import (\n")
+	for _, path := range paths {
+		fmt.Fprintf(&src, "%q\n", path)
+	}
+	src.WriteString(")\n")
+	src.Write(g.buf.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func (g *methodsGenerator) use(path string) {
+	g.imports[path] = filepath.Base(path)
+}
+
+// typeString returns the type expression valid in the generated file.
+func (g *methodsGenerator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.use(p.Path())
+		return p.Name()
+	})
+}
+
+// classify returns the cell kind of the type and its basic kind.
+func classify(t types.Type) (cellKind, types.BasicKind) {
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time" {
+			return cellTime, types.Invalid
+		}
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return cellJSON, types.Invalid
+	}
+	info := basic.Info()
+	switch {
+	case basic.Kind() == types.Uintptr || basic.Kind() == types.UnsafePointer:
+		return cellJSON, basic.Kind()
+	case info&types.IsInteger != 0 && info&types.IsUnsigned != 0:
+		return cellUint, basic.Kind()
+	case info&types.IsInteger != 0:
+		return cellInt, basic.Kind()
+	case info&types.IsFloat != 0:
+		return cellFloat, basic.Kind()
+	case info&types.IsString != 0:
+		return cellString, basic.Kind()
+	case info&types.IsBoolean != 0:
+		return cellBool, basic.Kind()
+	}
+	return cellJSON, basic.Kind()
+}
+
+// isSQLNullType reports whether t is one of the nullable types of database/sql, e.g. sql.NullString or sql.Null[T].
+// The library encodes their values if they are valid.
+func isSQLNullType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "database/sql" || !strings.HasPrefix(named.Obj().Name(), "Null") {
+		return false
+	}
+	s, ok := named.Underlying().(*types.Struct)
+	return ok && s.NumFields() == 2 && s.Field(1).Name() == "Valid"
+}
+
+// isValuer reports whether the field of type t implements driver.Valuer.
+func isValuer(t types.Type) bool {
+	return hasMethod(t, "Value", 0, 2)
+}
+
+// isScanner reports whether the pointer to t implements sql.Scanner.
+func isScanner(t types.Type) bool {
+	return hasMethod(t, "Scan", 1, 1)
+}
+
+// hasMethod reports whether the addressable value of type t has the method with the number of parameters and results.
+func hasMethod(t types.Type, name string, params, results int) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, name)
+	method, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := method.Type().(*types.Signature)
+	return sig.Params().Len() == params && sig.Results().Len() == results
+}
+
+// field is a struct field with its column name, time layout and float format.
+type structField struct {
+	*types.Var
+	column     string
+	timeFormat string
+	float      byte
+	prec       int
+	omitEmpty  bool
+	nulls      []string // null tokens, the first one is written for nil pointers
+	zeroNull   bool     // cells of zeros only are null
+	skip       bool     // the field has no column: it's unexported, its column name is "-" or it collects the extra columns
+}
+
+// null returns the text of null values: the first null token or an empty string.
+func (f *structField) null() string {
+	if len(f.nulls) > 0 {
+		return f.nulls[0]
+	}
+	return ""
+}
+
+func newStructField(v *types.Var, tag string) (*structField, error) {
+	if column := refName(v.Name(), reflect.StructTag(tag)); !v.Exported() || column == "-" {
+		return &structField{Var: v, skip: true}, nil
+	}
+	if _, ok := v.Type().Underlying().(*types.Interface); ok {
+		return nil, fmt.Errorf("field %s: interface fields aren't supported", v.Name())
+	}
+	f := &structField{Var: v, column: refName(v.Name(), reflect.StructTag(tag)), timeFormat: "time.RFC3339", float: 'g', prec: -1}
+	if layout, ok := reflect.StructTag(tag).Lookup("format"); ok {
+		f.timeFormat = strconv.Quote(layout)
+	}
+	if err := f.parseFwOptions(reflect.StructTag(tag)); err != nil {
+		return nil, fmt.Errorf("field %s: %w", v.Name(), err)
+	}
+	if json, ok := reflect.StructTag(tag).Lookup("json"); ok {
+		_, opts, _ := strings.Cut(json, ",")
+		f.omitEmpty = f.omitEmpty || slices.Contains(strings.Split(opts, ","), "omitempty")
+	}
+	return f, nil
+}
+
+// parseFwOptions mirrors the options of the fw tag which change the text of values: prec=N, fixed, exp, omitempty,
+// null=TOKEN|... and nullzeros.
+// Extra fields have no column of their own, they are skipped.
+func (f *structField) parseFwOptions(tag reflect.StructTag) error {
+	fw, ok := tag.Lookup("fw")
+	if !ok {
+		return nil
+	}
+	for _, opt := range strings.Split(fw, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "prec":
+			prec, err := strconv.Atoi(value)
+			if err != nil || prec < 0 {
+				return fmt.Errorf("invalid fw tag option %q", opt)
+			}
+			f.prec = prec
+		case "fixed":
+			f.float = 'f'
+		case "exp":
+			f.float = 'e'
+		case "omitempty":
+			f.omitEmpty = true
+		case "null":
+			f.nulls = append(f.nulls, strings.Split(value, "|")...)
+		case "nullzeros":
+			f.zeroNull = true
+		case "extra":
+			// the extra columns are handled by the library
+			f.skip = true
+		}
+	}
+	if f.prec >= 0 && f.float == 'g' {
+		f.float = 'f'
+	}
+	return nil
+}
+
+// refName mirrors the column name resolution of the library: fw tag name, column, json tags and the field name.
+func refName(name string, tag reflect.StructTag) string {
+	if fw, ok := tag.Lookup("fw"); ok {
+		if fwName, _, _ := strings.Cut(fw, ","); fwName != "" && !strings.Contains(fwName, "=") {
+			return fwName
+		}
+	}
+	if column, ok := tag.Lookup("column"); ok {
+		return column
+	}
+	if json, ok := tag.Lookup("json"); ok {
+		if column, _, _ := strings.Cut(json, ","); column != "" {
+			return column
+		}
+	}
+	return name
+}
+
+func (g *methodsGenerator) generateType(name string, s *types.Struct) error {
+	fields := make([]*structField, s.NumFields())
+	for i := range fields {
+		f, err := newStructField(s.Field(i), s.Tag(i))
+		if err != nil {
+			return err
+		}
+		fields[i] = f
+	}
+	if err := g.generateMarshal(name, fields); err != nil {
+		return err
+	}
+	g.generateUnmarshal(name, fields)
+	return nil
+}
+
+func (g *methodsGenerator) generateMarshal(name string, fields []*structField) error {
+	w := &g.buf
+	fmt.Fprintf(w, "\n// MarshalFixedWidth implements fwencoder.FixedWidthMarshaler.\n")
+	fmt.Fprintf(w, "func (v *%s) MarshalFixedWidth(buf []byte, ends []int) ([]byte, []int, error) {\n", name)
+	for _, f := range fields {
+		if t := valueType(f.Type()); !f.skip && usesJSON(t) {
+			fmt.Fprintf(w, "var (\nb []byte\nerr error\n)\n")
+			break
+		}
+	}
+	for _, f := range fields {
+		expr := "v." + f.Name()
+		switch t := valueType(f.Type()); {
+		case f.skip:
+			// the cells are indexed by the struct fields, fields without a column get empty cells
+		case f.omitEmpty:
+			// zero values are omitted before the null token is written, the same way as by the encoder
+			cond, err := g.zeroCondition(expr, f.Type())
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.Name(), err)
+			}
+			if t != f.Type() {
+				expr = "*" + expr
+			}
+			fmt.Fprintf(w, "if !(%s) {\n", cond)
+			g.renderCell(expr, t, f)
+			fmt.Fprintf(w, "}\n")
+		case t != f.Type():
+			fmt.Fprintf(w, "if %s == nil {\n", expr)
+			g.appendNull(f)
+			fmt.Fprintf(w, "} else {\n")
+			g.renderCell("*"+expr, t, f)
+			fmt.Fprintf(w, "}\n")
+		default:
+			g.renderCell(expr, t, f)
+		}
+		fmt.Fprintf(w, "ends = append(ends, len(buf))\n")
+	}
+	fmt.Fprintf(w, "return buf, ends, nil\n}\n")
+	return nil
+}
+
+// appendNull writes the statement appending the null token of the field to buf, null values are empty by default.
+func (g *methodsGenerator) appendNull(f *structField) {
+	if null := f.null(); null != "" {
+		fmt.Fprintf(&g.buf, "buf = append(buf, %q...)\n", null)
+	}
+}
+
+// usesJSON reports whether the value of type t is rendered as JSON.
+func usesJSON(t types.Type) bool {
+	if isSQLNullType(t) {
+		return usesJSON(t.Underlying().(*types.Struct).Field(0).Type())
+	}
+	kind, _ := classify(t)
+	return kind == cellJSON && !isValuer(t)
+}
+
+// zeroCondition returns the expression reporting whether the value is zero the same way as reflect.Value.IsZero.
+func (g *methodsGenerator) zeroCondition(expr string, t types.Type) (string, error) {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
+		return expr + " == nil", nil
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsString != 0:
+			return expr + ` == ""`, nil
+		case info&types.IsBoolean != 0:
+			return "!" + expr, nil
+		}
+		return expr + " == 0", nil
+	}
+	if !types.Comparable(t) {
+		return "", fmt.Errorf("omitempty isn't supported for %s", t)
+	}
+	return fmt.Sprintf("%s == (%s{})", expr, g.typeString(t)), nil
+}
+
+// valueType returns the type of the value the encoder renders: the element of a pointer or the type itself.
+func valueType(t types.Type) types.Type {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+// renderCell writes the statements appending the text of the non-pointer value to buf.
+func (g *methodsGenerator) renderCell(expr string, t types.Type, f *structField) {
+	w := &g.buf
+	if strings.HasPrefix(expr, "*") && (isSQLNullType(t) || isValuer(t)) {
+		expr = "(" + expr + ")"
+	}
+	switch {
+	case isSQLNullType(t):
+		value := t.Underlying().(*types.Struct).Field(0)
+		fmt.Fprintf(w, "if !%s.Valid {\n", expr)
+		g.appendNull(f)
+		fmt.Fprintf(w, "} else {\n")
+		g.renderCell(expr+"."+value.Name(), value.Type(), f)
+		fmt.Fprintf(w, "}\n")
+		return
+	case isValuer(t):
+		g.renderValuer(expr, f)
+		return
+	}
+	kind, basic := classify(t)
+	switch kind {
+	case cellInt:
+		g.use("strconv")
+		fmt.Fprintf(w, "buf = strconv.AppendInt(buf, %s, 10)\n", convert(expr, t, basic, types.Int64, "int64"))
+	case cellUint:
+		g.use("strconv")
+		fmt.Fprintf(w, "buf = strconv.AppendUint(buf, %s, 10)\n", convert(expr, t, basic, types.Uint64, "uint64"))
+	case cellFloat:
+		g.use("strconv")
+		bitSize := 64
+		if basic == types.Float32 {
+			bitSize = 32
+		}
+		fmt.Fprintf(w, "buf = strconv.AppendFloat(buf, %s, %q, %d, %d)\n",
+			convert(expr, t, basic, types.Float64, "float64"), f.float, f.prec, bitSize)
+	case cellString:
+		fmt.Fprintf(w, "buf = append(buf, %s...)\n", convert(expr, t, basic, types.String, "string"))
+	case cellBool:
+		g.use("strconv")
+		fmt.Fprintf(w, "buf = strconv.AppendBool(buf, %s)\n", convert(expr, t, basic, types.Bool, "bool"))
+	case cellTime:
+		g.use("time")
+		if strings.HasPrefix(expr, "*") {
+			expr = "(" + expr + ")"
+		}
+		fmt.Fprintf(w, "buf = %s.AppendFormat(buf, %s)\n", expr, f.timeFormat)
+	case cellJSON:
+		g.use("encoding/json")
+		fmt.Fprintf(w, "if b, err = json.Marshal(%s); err != nil {\nreturn nil, nil, err\n}\n", expr)
+		fmt.Fprintf(w, "buf = append(buf, b...)\n")
+	}
+}
+
+// renderValuer writes the statements appending the text of the driver.Value of the value to buf.
+func (g *methodsGenerator) renderValuer(expr string, f *structField) {
+	g.use("fmt")
+	g.use("strconv")
+	g.use("time")
+	fmt.Fprintf(&g.buf, "{\nx, err := %s.Value()\nif err != nil {\nreturn nil, nil, err\n}\n", expr)
+	fmt.Fprintf(&g.buf, "switch x := x.(type) {\ncase nil:\n")
+	g.appendNull(f)
+	fmt.Fprintf(&g.buf, "case []byte:\nbuf = append(buf, x...)\n"+
+		"case string:\nbuf = append(buf, x...)\n"+
+		"case float64:\nbuf = strconv.AppendFloat(buf, x, %q, %d, 64)\n"+
+		"case time.Time:\nbuf = x.AppendFormat(buf, %s)\n"+
+		"default:\nbuf = fmt.Append(buf, x)\n}\n}\n", f.float, f.prec, f.timeFormat)
+}
+
+// convert returns the expression converted to the basic type unless it already has this type.
+func convert(expr string, t types.Type, kind, target types.BasicKind, targetName string) string {
+	if _, named := t.(*types.Named); !named && kind == target {
+		return expr
+	}
+	if strings.HasPrefix(expr, "*") {
+		expr = "(" + expr + ")"
+	}
+	return targetName + "(" + expr + ")"
+}
+
+func (g *methodsGenerator) generateUnmarshal(name string, fields []*structField) {
+	w := &g.buf
+	fmt.Fprintf(w, "\n// UnmarshalFixedWidth implements fwencoder.FixedWidthUnmarshaler.\n")
+	fmt.Fprintf(w, "func (v *%s) UnmarshalFixedWidth(cells [][]byte, columns []int) error {\n", name)
+	for j, f := range fields {
+		if f.skip {
+			continue
+		}
+		g.use("bytes")
+		fmt.Fprintf(w, "if i := columns[%d]; i >= 0 {\nraw := bytes.TrimSpace(cells[i])\n", j)
+		if conditions := f.notNullConditions(); len(conditions) > 0 {
+			// null cells leave the zero value
+			fmt.Fprintf(w, "if %s {\n", strings.Join(conditions, " && "))
+			g.parseCell(f)
+			fmt.Fprintf(w, "}\n")
+		} else {
+			g.parseCell(f)
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+	fmt.Fprintf(w, "return nil\n}\n")
+}
+
+// notNullConditions returns the conditions raw must meet to be parsed, the library leaves the zero value
+// for empty cells of pointer, sql null, sql.Scanner and omitempty fields and for the null tokens of the fw tag.
+func (f *structField) notNullConditions() []string {
+	var conditions []string
+	t := valueType(f.Type())
+	if _, isPointer := f.Type().Underlying().(*types.Pointer); isPointer || f.omitEmpty || isSQLNullType(t) || isScanner(t) {
+		conditions = append(conditions, `len(raw) != 0`)
+	}
+	for _, token := range f.nulls {
+		conditions = append(conditions, fmt.Sprintf("string(raw) != %q", token))
+	}
+	if f.zeroNull {
+		conditions = append(conditions, `(len(raw) == 0 || len(bytes.Trim(raw, "0")) != 0)`)
+	}
+	return conditions
+}
+
+// parseCell writes the statements parsing raw into the field.
+func (g *methodsGenerator) parseCell(f *structField) {
+	t, isPointer := f.Type(), false
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		if kind, _ := classify(ptr.Elem()); kind != cellJSON || isSQLNullType(ptr.Elem()) || isScanner(ptr.Elem()) {
+			t, isPointer = ptr.Elem(), true
+		}
+	}
+	target := "v." + f.Name()
+	switch {
+	case isSQLNullType(t):
+		// the library parses the value of the sql null type and marks it valid
+		if isPointer {
+			fmt.Fprintf(&g.buf, "%s = new(%s)\n", target, g.typeString(t))
+		}
+		value := t.Underlying().(*types.Struct).Field(0)
+		g.parseValue(f, target+"."+value.Name(), value.Type(), false)
+		fmt.Fprintf(&g.buf, "%s.Valid = true\n", target)
+	case isScanner(t):
+		g.use("fmt")
+		scanner := target
+		if isPointer {
+			scanner = "p"
+			fmt.Fprintf(&g.buf, "p := new(%s)\n", g.typeString(t))
+		}
+		fmt.Fprintf(&g.buf, "if err := %s.Scan(string(raw)); err != nil {\n"+
+			"return fmt.Errorf(`filed casting \"%%s\" to \"%s:%%T\": %%w`, raw, %s, err)\n}\n", scanner, f.Name(), target)
+		if isPointer {
+			fmt.Fprintf(&g.buf, "%s = p\n", target)
+		}
+	default:
+		g.parseValue(f, target, t, isPointer)
+	}
+}
+
+// parseValue writes the statements parsing raw into the target expression of type t or of the pointer to t.
+func (g *methodsGenerator) parseValue(f *structField, target string, t types.Type, isPointer bool) {
+	w := &g.buf
+	kind, basic := classify(t)
+
+	castingError := func() {
+		g.use("fmt")
+		fmt.Fprintf(w, "if err != nil {\nreturn fmt.Errorf(`filed casting \"%%s\" to \"%s:%%T\": %%w`, raw, %s, err)\n}\n",
+			f.Name(), target)
+	}
+	overflowCheck := func() {
+		if check, ok := overflowChecks[basic]; ok {
+			g.use("fmt")
+			g.use("math")
+			fmt.Fprintf(w, "if %s {\nreturn fmt.Errorf(`value %%v is too big for field %s:%%T`, x, %s)\n}\n",
+				check, f.Name(), target)
+		}
+	}
+
+	switch kind {
+	case cellInt:
+		g.use("strconv")
+		fmt.Fprintf(w, "x, err := strconv.ParseInt(string(raw), 10, 0)\n")
+		castingError()
+		overflowCheck()
+	case cellUint:
+		g.use("strconv")
+		fmt.Fprintf(w, "x, err := strconv.ParseUint(string(raw), 10, 64)\n")
+		castingError()
+		overflowCheck()
+	case cellFloat:
+		g.use("strconv")
+		fmt.Fprintf(w, "x, err := strconv.ParseFloat(string(raw), 64)\n")
+		castingError()
+		overflowCheck()
+	case cellString:
+		fmt.Fprintf(w, "x := string(raw)\n")
+	case cellBool:
+		g.use("strconv")
+		fmt.Fprintf(w, "x, err := strconv.ParseBool(string(raw))\n")
+		castingError()
+	case cellTime:
+		g.use("time")
+		fmt.Fprintf(w, "x, err := time.Parse(%s, string(raw))\n", f.timeFormat)
+		castingError()
+	case cellJSON:
+		g.use("encoding/json")
+		g.use("fmt")
+		fmt.Fprintf(w, "var x %s\n", g.typeString(t))
+		fmt.Fprintf(w, "if err := json.Unmarshal(raw, &x); err != nil {\n"+
+			"return fmt.Errorf(`can't unmarshal '\"%%s\" to %%T: %%w`, raw, %s, err)\n}\n", target)
+		fmt.Fprintf(w, "%s = x\n", target)
+		return
+	}
+
+	value := "x"
+	if _, named := t.(*types.Named); kind != cellTime && (named || basicKindOf(kind) != basic) {
+		value = g.typeString(t) + "(" + value + ")"
+	}
+	switch {
+	case !isPointer:
+		fmt.Fprintf(w, "%s = %s\n", target, value)
+	case value == "x":
+		fmt.Fprintf(w, "%s = &%s\n", target, value)
+	default:
+		fmt.Fprintf(w, "p := %s\n%s = &p\n", value, target)
+	}
+}
+
+// basicKindOf returns the basic kind of the value parsed for the cell kind.
+func basicKindOf(kind cellKind) types.BasicKind {
+	switch kind {
+	case cellInt:
+		return types.Int64
+	case cellUint:
+		return types.Uint64
+	case cellFloat:
+		return types.Float64
+	case cellString:
+		return types.String
+	case cellBool:
+		return types.Bool
+	}
+	return types.Invalid
+}
@@ -10,9 +10,11 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -22,14 +24,19 @@ const (
 )
 
 type fwColumn struct {
-	name  string
-	start int
-	end   int
+	name   string
+	header string // the header text matched by the column name or its alias
+	start  int
+	end    int
+	align  Alignment
+	pad    rune
 }
 
 var (
 	// ErrIncorrectInputValue represents wrong input param
 	ErrIncorrectInputValue = errors.New("value is not a pointer to slice of structs")
+	// ErrHeaderNotFound represents a failed search for the header line, see WithHeaderSearch
+	ErrHeaderNotFound = errors.New("header line not found")
 )
 
 // Unmarshal parses the fixed width table data and stores the result in the value pointed to by v.
@@ -50,12 +57,28 @@ var (
 //	    BDate    time.Time `column:"Birthday" format:"2006/01/02"`
 //	    Postcode int       `json:"Zip"`
 //	}
-func Unmarshal(data []byte, v any) error {
-	return UnmarshalReader(bytes.NewReader(data), v)
+//
+// Lines may be terminated with \n, \r\n or \r. A leading UTF-8 or UTF-16 byte order mark is stripped,
+// UTF-16 input is transcoded to UTF-8.
+//
+// Every data line must have the same length as the header line. This can be relaxed with the WithPadShortLines,
+// WithOpenEndedLastColumn and WithTruncateLongLines options.
+//
+// The first line which is not skipped is treated as the header line. Preamble, comments and blank lines can be skipped
+// with the WithSkipLines, WithCommentPrefix, WithCommentPattern and WithSkipBlankLines options, and WithHeaderSearch
+// locates the header line by the column names.
+//
+// A trailer line with the record count and control totals can be declared with the WithTrailer option.
+//
+// When the columns aren't known at compile time, v can be a pointer to []map[string]string or []Record.
+// The columns are discovered from the header line then: every word of the header starts a new column,
+// or the header is split at the separator set with WithSeparator.
+func Unmarshal(data []byte, v any, opts ...DecoderOption) error {
+	return UnmarshalReader(bytes.NewReader(data), v, opts...)
 }
 
 // UnmarshalReader behaves the same as Unmarshal, but reads data from io.Reader
-func UnmarshalReader(reader io.Reader, v any) (err error) {
+func UnmarshalReader(reader io.Reader, v any, opts ...DecoderOption) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -65,15 +88,260 @@ func UnmarshalReader(reader io.Reader, v any) (err error) {
 		}
 	}()
 
-	sliceItemType, isSliceItemPtr, err := validateInput(v)
+	options := newDecoderOptions(opts)
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	target, err := newDecodeTarget(v, options)
+	if err != nil {
+		return err
+	}
+
+	reader, err = newBOMReader(reader)
 	if err != nil {
 		return err
 	}
 
+	return parseData(reader, target, options)
+}
+
+// decodeTarget collects decoded rows into the value passed to UnmarshalReader.
+type decodeTarget interface {
+	trailerSource
+	// parseHeader locates the target columns in the header line. It reports whether the line contains all expected columns.
+	parseHeader(headerLine string) (columns []fwColumn, complete bool, err error)
+	// setColumns sets the columns defined by a layout instead of the header line
+	setColumns(columns []fwColumn)
+	// validateColumns checks the columns set by a layout, see WithStrictHeaders
+	validateColumns() error
+	// validateHeader checks the columns found in the accepted header line, see WithStrictHeaders
+	validateHeader(headerLine string) error
+	// appendRow converts the cells of a data line into a new item, cells[i] belongs to the column i
+	// of the header or the layout. The cells refer to the scanner buffer and must be copied to be kept.
+	appendRow(cells [][]byte) error
+}
+
+func newDecodeTarget(v any, options *decoderOptions) (decodeTarget, error) {
+	if target, ok := newDynamicDecodeTarget(v, options); ok {
+		if options.headerSearchLines > 0 && options.layout == nil {
+			// any line is a complete header of records and maps
+			return nil, errors.New("header search requires a struct or a layout")
+		}
+		return target, nil
+	}
+
+	sliceItemType, isSliceItemPtr, err := validateInput(v)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := planOf(sliceItemType)
+	if plan.tagErr != nil {
+		return nil, plan.tagErr
+	}
 	slice := reflect.ValueOf(v).Elem()
 	slice.Set(slice.Slice(0, 0))
+	return &structDecodeTarget{
+		slice:          slice,
+		itemType:       sliceItemType,
+		isSliceItemPtr: isSliceItemPtr,
+		plan:           plan,
+		lenientHeaders: options.lenientHeaders,
+		strictHeaders:  options.strictHeaders,
+		emptyAsZero:    options.emptyAsZero,
+	}, nil
+}
+
+type structDecodeTarget struct {
+	slice          reflect.Value
+	itemType       reflect.Type
+	isSliceItemPtr bool
+	plan           *typePlan
+	lenientHeaders bool
+	strictHeaders  bool
+	emptyAsZero    bool
+
+	columns      []fwColumn
+	columnFields [][]int       // columnFields[i] are the indexes of the fields set from the column i
+	extraColumns []int         // the indexes of the columns collected by the extra field
+	extraHeader  *recordHeader // the header of the extra records
+	fieldColumns []int         // fieldColumns[i] is the column of the i-th struct field or -1, see FixedWidthUnmarshaler
+	rowColumns   []int         // fieldColumns without the blank cells of the row, used with emptyAsZero
+}
+
+func (t *structDecodeTarget) parseHeader(headerLine string) (columns []fwColumn, complete bool, err error) {
+	if t.plan.headerErr != nil {
+		return nil, false, t.plan.headerErr
+	}
+	columns = parseHeaders(headerLine, t.plan.columns, t.headerPatterns())
+	// the padding of the fw tags is stripped from the cells
+	for i := range columns {
+		if tag := t.plan.fields[t.plan.index[columns[i].name]].tag; tag != nil {
+			columns[i].align, columns[i].pad = tag.align, tag.pad
+		}
+	}
+	complete = len(columns) == len(t.plan.columns)
+	if t.plan.extra != nil {
+		columns = append(columns, uncoveredColumns(headerLine, columns)...)
+	}
+	t.setColumns(columns)
+	return columns, complete, nil
+}
+
+func (t *structDecodeTarget) headerPatterns() []*regexp.Regexp {
+	if t.lenientHeaders {
+		return t.plan.lenientPatterns
+	}
+	return t.plan.headerPatterns
+}
+
+func (t *structDecodeTarget) setColumns(columns []fwColumn) {
+	t.columns = columns
+	t.columnFields = make([][]int, len(columns))
+	t.extraColumns = t.extraColumns[:0]
+	var extraNames []string
+	seen := make(map[string]bool, len(columns))
+	for i, c := range columns {
+		if _, ok := t.plan.index[c.name]; !ok && t.plan.extra != nil {
+			t.extraColumns = append(t.extraColumns, i)
+			extraNames = append(extraNames, c.name)
+			continue
+		}
+		if seen[c.name] {
+			continue
+		}
+		seen[c.name] = true
+		for j := range t.plan.fields {
+			if t.plan.fields[j].name == c.name {
+				t.columnFields[i] = append(t.columnFields[i], j)
+			}
+		}
+	}
+	if t.plan.unmarshal {
+		t.fieldColumns = slices.Repeat([]int{-1}, t.itemType.NumField())
+		for i, fields := range t.columnFields {
+			for _, j := range fields {
+				t.fieldColumns[t.plan.fields[j].Index[0]] = i
+			}
+		}
+	}
+	t.extraHeader = newRecordHeader(extraNames)
+	if len(extraNames) > 0 {
+		line := lineOrder(columns)
+		t.extraHeader.line = line
+		if t.plan.extra.Type == stringMapType {
+			t.plan.extraLine.Store(&line)
+		}
+	}
+}
 
-	return parseData(reader, slice, sliceItemType, isSliceItemPtr)
+// lineOrder returns the column names in order of their positions in the line.
+func lineOrder(columns []fwColumn) []string {
+	sorted := slices.SortedStableFunc(slices.Values(columns), func(a, b fwColumn) int { return a.start - b.start })
+	names := make([]string, len(sorted))
+	for i, c := range sorted {
+		names[i] = c.name
+	}
+	return names
+}
+
+func (t *structDecodeTarget) appendRow(cells [][]byte) error {
+	// the item is decoded in place, the slice is truncated back on error
+	n := t.slice.Len()
+	t.slice.Grow(1)
+	t.slice.SetLen(n + 1)
+	item := t.slice.Index(n)
+	item.SetZero()
+	if t.isSliceItemPtr {
+		item.Set(reflect.New(t.itemType))
+		item = item.Elem()
+	}
+
+	var err error
+	if t.plan.unmarshal {
+		err = t.unmarshal(item, cells)
+	} else {
+		err = t.setFields(item, cells)
+	}
+	if err != nil {
+		t.slice.Index(n).SetZero()
+		t.slice.SetLen(n)
+	}
+	return err
+}
+
+func (t *structDecodeTarget) setFields(item reflect.Value, cells [][]byte) error {
+	for i, cell := range cells {
+		if len(t.columnFields[i]) == 0 || t.emptyAsZero && isBlank(cell) {
+			continue
+		}
+		for _, fieldIndex := range t.columnFields[i] {
+			field := &t.plan.fields[fieldIndex]
+			if err := field.set(item.Field(field.Index[0]), cell); err != nil {
+				return err
+			}
+		}
+	}
+	t.setExtra(item, cells)
+	return nil
+}
+
+// setExtra collects the cells of the columns unknown to the struct into the extra field.
+func (t *structDecodeTarget) setExtra(item reflect.Value, cells [][]byte) {
+	if len(t.extraColumns) == 0 {
+		return
+	}
+	field := item.Field(t.plan.extra.Index[0])
+	if field.Type() == recordType {
+		values := make([]string, len(t.extraColumns))
+		for i, column := range t.extraColumns {
+			values[i] = string(cells[column])
+		}
+		field.Set(reflect.ValueOf(Record{header: t.extraHeader, values: values}))
+		return
+	}
+	extra := make(map[string]string, len(t.extraColumns))
+	for _, column := range t.extraColumns {
+		extra[t.columns[column].name] = string(bytes.TrimSpace(cells[column]))
+	}
+	field.Set(reflect.ValueOf(extra))
+}
+
+func (t *structDecodeTarget) unmarshal(item reflect.Value, cells [][]byte) error {
+	columns := t.fieldColumns
+	if t.emptyAsZero {
+		// blank cells are missing, they leave the zero value
+		columns = t.rowColumns[:0]
+		for _, column := range t.fieldColumns {
+			if column >= 0 && isBlank(cells[column]) {
+				column = -1
+			}
+			columns = append(columns, column)
+		}
+		t.rowColumns = columns
+	}
+	if err := item.Addr().Interface().(FixedWidthUnmarshaler).UnmarshalFixedWidth(cells, columns); err != nil {
+		return err
+	}
+	t.setExtra(item, cells)
+	return nil
+}
+
+func isBlank(cell []byte) bool {
+	return len(bytes.TrimSpace(cell)) == 0
+}
+
+func (t *structDecodeTarget) recordCount() int {
+	return t.slice.Len()
+}
+
+func (t *structDecodeTarget) sumColumn(column string, decimals int) (int64, error) {
+	source, err := newStructEncodeSource(t.slice, t.itemType, nil)
+	if err != nil {
+		return 0, err
+	}
+	return source.sumColumn(column, decimals)
 }
 
 func validateInput(v any) (sliceItemType reflect.Type, isSliceItemPtr bool, err error) {
@@ -100,119 +368,320 @@ func validateInput(v any) (sliceItemType reflect.Type, isSliceItemPtr bool, err
 	return sliceItemType, isSliceItemPtr, nil
 }
 
-func parseData(reader io.Reader, slice reflect.Value, sliceItemType reflect.Type, isSliceItemPtr bool) error {
+func parseData(reader io.Reader, target decodeTarget, options *decoderOptions) error {
 	scanner := bufio.NewScanner(reader)
-	fieldsIndex := make(map[string]string)
-	isHeaderParsed := false
+	scanner.Split(scanLines)
+	header, err := newHeaderState(target, options)
+	if err != nil {
+		return err
+	}
+	slicer := &rowSlicer{header: header, options: options}
+	appendLine := func(line []byte, lineNum int) error {
+		cells, err := slicer.slice(line)
+		if err != nil {
+			return fmt.Errorf("wrong data length in line %d: %w", lineNum, err)
+		}
+		if err := target.appendRow(cells); err != nil {
+			return fmt.Errorf("error in line %d: %w", lineNum, err)
+		}
+		return nil
+	}
+	var trailer trailerCandidate
 	lineNum := 0
-	headersLength := 0
-	columnNames := getColumns(sliceItemType)
-	var columns []fwColumn
 
 	for scanner.Scan() {
 		lineNum++
-		line := scanner.Text()
-		lineRunes := []rune(line)
-		if !isHeaderParsed {
-			isHeaderParsed = true
-			headersLength = len(lineRunes)
-			var err error
-			columns, err = parseHeaders(line, columnNames)
-			if err != nil {
+		line := scanner.Bytes()
+		if isSkippedLine(line, lineNum, options) {
+			continue
+		}
+		if !header.parsed {
+			if err := header.parse(string(line)); err != nil {
 				return err
 			}
 			continue
 		}
-		if len(lineRunes) != headersLength {
-			return fmt.Errorf("wrong data length in line %d", lineNum)
+		if options.trailer != nil {
+			if trailer.held() && isBlank(line) {
+				trailer.hold(line, lineNum)
+				continue
+			}
+			if err := trailer.release(appendLine); err != nil {
+				return err
+			}
+			if options.trailer.isTrailerLine(line) {
+				trailer.hold(line, lineNum)
+				continue
+			}
+		}
+		if err := appendLine(line, lineNum); err != nil {
+			return err
 		}
+	}
 
-		for _, prnColumn := range columns {
-			fieldsIndex[prnColumn.name] = string(lineRunes[prnColumn.start:prnColumn.end])
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !header.parsed && header.candidates > 0 {
+		return ErrHeaderNotFound
+	}
+	if options.trailer != nil {
+		if !trailer.held() {
+			return ErrTrailerNotFound
+		}
+		if err := options.trailer.verify(string(trailer.lines[0]), target); err != nil {
+			return fmt.Errorf("error in line %d: %w", trailer.lineNums[0], err)
 		}
+	}
+	return nil
+}
 
-		newItem, err := createObject(fieldsIndex, sliceItemType)
-		if err != nil {
-			return fmt.Errorf("error in line %d: %w", lineNum, err)
+// headerState locates the header line and holds the column positions.
+type headerState struct {
+	target     decodeTarget
+	options    *decoderOptions
+	parsed     bool
+	candidates int
+	lineLength int
+	columns    []fwColumn
+}
+
+func newHeaderState(target decodeTarget, options *decoderOptions) (*headerState, error) {
+	h := &headerState{target: target, options: options}
+	if options.layout != nil {
+		h.columns = options.layout.fwColumns()
+		h.lineLength = options.layout.LineLength()
+		h.parsed = !options.header
+		target.setColumns(h.columns)
+		if err := target.validateColumns(); err != nil {
+			return nil, err
 		}
-		if !isSliceItemPtr {
-			newItem = newItem.Elem()
+	}
+	return h, nil
+}
+
+func (h *headerState) parse(line string) error {
+	var complete bool
+	if h.options.layout != nil {
+		// positions are defined by the layout, the header line is only located
+		complete = true
+		for _, name := range h.options.layout.Names() {
+			complete = complete && strings.Contains(line, name)
 		}
-		slice.Set(reflect.Append(slice, newItem))
+	} else {
+		columns, ok, err := h.target.parseHeader(line)
+		if err != nil {
+			return err
+		}
+		h.columns, complete = columns, ok
+		h.lineLength = utf8.RuneCountInString(line)
 	}
 
+	if h.options.headerSearchLines > 0 && !complete {
+		h.candidates++
+		if h.candidates >= h.options.headerSearchLines {
+			return ErrHeaderNotFound
+		}
+		return nil
+	}
+	h.parsed = true
+	if h.options.layout == nil {
+		if err := h.target.validateHeader(line); err != nil {
+			return err
+		}
+	}
+	if h.options.headerMatch != nil {
+		for _, c := range h.columns {
+			if c.header != "" {
+				h.options.headerMatch(c.name, c.header)
+			}
+		}
+	}
 	return nil
 }
 
-func getRefName(field *reflect.StructField) string {
-	if name, ok := field.Tag.Lookup(columnTagName); ok {
-		return name
+// isSkippedLine reports whether the line is a part of the preamble, a comment or a blank line
+// which should be ignored according to the decoder options.
+func isSkippedLine(line []byte, lineNum int, options *decoderOptions) bool {
+	if lineNum <= options.skipLines {
+		return true
 	}
-	if name, ok := field.Tag.Lookup(jsonTagName); ok {
-		return name
+	if options.skipBlankLines && len(bytes.TrimSpace(line)) == 0 {
+		return true
 	}
-	return field.Name
+	for _, prefix := range options.commentPrefixes {
+		if hasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return options.commentPattern != nil && options.commentPattern.Match(line)
 }
 
-func createObject(fieldsIndex map[string]string, t reflect.Type) (reflect.Value, error) {
-	sp := reflect.New(t)
-	s := sp.Elem()
-	fieldsCount := s.NumField()
-	for fieldIndex := range fieldsCount {
-		currentField := s.Field(fieldIndex)
-		typeField := s.Type().Field(fieldIndex)
-		refName := getRefName(&typeField)
+// hasPrefix is bytes.HasPrefix for a string prefix which doesn't convert the prefix.
+func hasPrefix(line []byte, prefix string) bool {
+	return len(line) >= len(prefix) && string(line[:len(prefix)]) == prefix
+}
 
-		rawValue, ok := fieldsIndex[refName]
-		if !ok {
-			continue
+// scanLines is a split function for a bufio.Scanner that returns each line of text with
+// the line ending stripped. Unlike bufio.ScanLines it accepts \n, \r\n and a lone \r as line endings.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
 		}
-		if err := setFieldValue(currentField, &typeField, rawValue); err != nil {
-			return s, err
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
 		}
+		// request more data to find out whether \r is followed by \n
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
 	}
-	return sp, nil
+	return 0, nil, nil
 }
 
-func setFieldValue(field reflect.Value, structField *reflect.StructField, rawValue string) error {
-	rawValue = strings.TrimSpace(rawValue)
-	fieldKind := field.Type().Kind()
-	isPointer := fieldKind == reflect.Ptr
-	if isPointer {
-		fieldKind = field.Type().Elem().Kind()
-	}
-	switch fieldKind {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return setIntFieldValue(field, structField, rawValue, isPointer)
-	case reflect.Float32, reflect.Float64:
-		return setFloatFieldValue(field, structField, rawValue, isPointer)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return setUintFieldValue(field, structField, rawValue, isPointer)
-	case reflect.String:
-		return setStringFieldValue(field, rawValue, isPointer)
-	case reflect.Bool:
-		return setBoolFieldValue(field, structField, rawValue, isPointer)
-	case reflect.Struct:
-		if field.Type() == reflect.TypeOf(time.Time{}) || field.Type() == reflect.TypeOf(&time.Time{}) {
-			return setTimeFieldValue(field, structField, rawValue, isPointer)
-		}
-		fallthrough
-	default:
-		v := reflect.New(field.Type())
-		err := json.Unmarshal([]byte(rawValue), v.Interface())
-		if err != nil {
-			return fmt.Errorf(`can't unmarshal '"%s" to %v: %w`, rawValue, field.Type(), err)
+// rowSlicer cuts data lines into cells at the column positions. The positions are counted in characters,
+// they are used as byte offsets of ASCII lines, other lines get a table of character offsets.
+type rowSlicer struct {
+	header  *headerState
+	options *decoderOptions
+	cells   [][]byte
+	offsets []int // offsets[i] is the byte offset of the character i of a non-ASCII line
+	padded  []byte
+}
+
+// slice returns the cells of the line, the cells are valid until the next call.
+func (s *rowSlicer) slice(line []byte) ([][]byte, error) {
+	ascii := isASCII(line)
+	lineLength := len(line)
+	if !ascii {
+		lineLength = utf8.RuneCount(line)
+	}
+	length, err := normalizeLineLength(lineLength, s.header.lineLength, s.header.columns, s.options)
+	if err != nil {
+		return nil, err
+	}
+	if length > lineLength {
+		s.padded = append(s.padded[:0], line...)
+		for range length - lineLength {
+			s.padded = append(s.padded, ' ')
+		}
+		line = s.padded
+	}
+	if !ascii {
+		s.offsets = s.offsets[:0]
+		for i := range string(line) {
+			s.offsets = append(s.offsets, i)
 		}
-		field.Set(v.Elem())
+		s.offsets = append(s.offsets, len(line))
 	}
+
+	s.cells = s.cells[:0]
+	for _, c := range s.header.columns {
+		start, end := c.start, c.end
+		if s.options.openEndedLastColumn && end == s.header.lineLength {
+			end = length
+		}
+		if !ascii {
+			start, end = s.offsets[start], s.offsets[end]
+		}
+		cell := line[start:end]
+		if c.pad != 0 && c.pad != ' ' {
+			cell = []byte(stripPadding(string(cell), c.align, c.pad))
+		}
+		s.cells = append(s.cells, cell)
+	}
+	return s.cells, nil
+}
+
+func isASCII(line []byte) bool {
+	for _, b := range line {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLineLength checks the data line length against the header line length and returns the length
+// the line is padded or truncated to according to the decoder options.
+func normalizeLineLength(lineLength, headersLength int, columns []fwColumn, options *decoderOptions) (int, error) {
+	if lineLength == headersLength {
+		return lineLength, nil
+	}
+
+	if lineLength > headersLength {
+		switch {
+		case options.openEndedLastColumn:
+			return lineLength, nil
+		case options.truncateLongLines:
+			return headersLength, nil
+		default:
+			return 0, fmt.Errorf("expected %d characters, got %d", headersLength, lineLength)
+		}
+	}
+
+	if options.openEndedLastColumn {
+		// the last column may be shorter, but all other columns must be present
+		minLength := 0
+		for _, c := range columns {
+			if c.end != headersLength {
+				minLength = max(minLength, c.end)
+			} else {
+				minLength = max(minLength, c.start)
+			}
+		}
+		if lineLength >= minLength {
+			return lineLength, nil
+		}
+	}
+
+	if !options.padShortLines {
+		return 0, fmt.Errorf("expected %d characters, got %d", headersLength, lineLength)
+	}
+	return headersLength, nil
+}
+
+func getRefName(field *reflect.StructField) string {
+	if name, _ := splitFwTag(field); name != "" {
+		return name
+	}
+	if name, ok := field.Tag.Lookup(columnTagName); ok {
+		return name
+	}
+	if tag, ok := field.Tag.Lookup(jsonTagName); ok {
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func setJSONFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte) error {
+	v := reflect.New(structField.Type)
+	err := json.Unmarshal(rawValue, v.Interface())
+	if err != nil {
+		return fmt.Errorf(`can't unmarshal '"%s" to %v: %w`, rawValue, structField.Type, err)
+	}
+	field.Set(v.Elem())
 	return nil
 }
 
 //nolint:dupl // it's not a duplicate
-func setIntFieldValue(field reflect.Value, structField *reflect.StructField, rawValue string, isPointer bool) error {
-	value, err := strconv.ParseInt(rawValue, 10, 0)
+func setIntFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error {
+	value, err := strconv.ParseInt(string(rawValue), 10, 0)
 	if err != nil {
-		return newCastingError(err, rawValue, structField)
+		return newCastingError(err, string(rawValue), structField)
 	}
 	if isPointer {
 		v := reflect.New(field.Type().Elem())
@@ -230,10 +699,10 @@ func setIntFieldValue(field reflect.Value, structField *reflect.StructField, raw
 	return nil
 }
 
-func setFloatFieldValue(field reflect.Value, structField *reflect.StructField, rawValue string, isPointer bool) error {
-	value, err := strconv.ParseFloat(rawValue, 64)
+func setFloatFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error {
+	value, err := strconv.ParseFloat(string(rawValue), 64)
 	if err != nil {
-		return newCastingError(err, rawValue, structField)
+		return newCastingError(err, string(rawValue), structField)
 	}
 	if isPointer {
 		v := reflect.New(field.Type().Elem())
@@ -252,10 +721,10 @@ func setFloatFieldValue(field reflect.Value, structField *reflect.StructField, r
 }
 
 //nolint:dupl // it's not a duplicate
-func setUintFieldValue(field reflect.Value, structField *reflect.StructField, rawValue string, isPointer bool) error {
-	value, err := strconv.ParseUint(rawValue, 10, 64)
+func setUintFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error {
+	value, err := strconv.ParseUint(string(rawValue), 10, 64)
 	if err != nil {
-		return newCastingError(err, rawValue, structField)
+		return newCastingError(err, string(rawValue), structField)
 	}
 	if isPointer {
 		v := reflect.New(field.Type().Elem())
@@ -273,19 +742,20 @@ func setUintFieldValue(field reflect.Value, structField *reflect.StructField, ra
 	return nil
 }
 
-func setStringFieldValue(field reflect.Value, rawValue string, isPointer bool) error {
+func setStringFieldValue(field reflect.Value, rawValue []byte, isPointer bool) error {
+	value := string(rawValue)
 	if isPointer {
-		field.Set(reflect.ValueOf(&rawValue))
+		field.Set(reflect.ValueOf(&value))
 	} else {
-		field.SetString(rawValue)
+		field.SetString(value)
 	}
 	return nil
 }
 
-func setBoolFieldValue(field reflect.Value, structField *reflect.StructField, rawValue string, isPointer bool) error {
-	value, err := strconv.ParseBool(rawValue)
+func setBoolFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error {
+	value, err := strconv.ParseBool(string(rawValue))
 	if err != nil {
-		return newCastingError(err, rawValue, structField)
+		return newCastingError(err, string(rawValue), structField)
 	}
 	if isPointer {
 		field.Set(reflect.ValueOf(&value))
@@ -295,23 +765,29 @@ func setBoolFieldValue(field reflect.Value, structField *reflect.StructField, ra
 	return nil
 }
 
-func setTimeFieldValue(field reflect.Value, structField *reflect.StructField, rawValue string, isPointer bool) error {
-	timeFormat, ok := structField.Tag.Lookup(format)
-	if !ok {
-		timeFormat = time.RFC3339
-	}
-	t, err := time.Parse(timeFormat, rawValue)
+func setTimeFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error {
+	t, err := time.Parse(getTimeFormat(structField), string(rawValue))
 	if err != nil {
-		return newCastingError(err, rawValue, structField)
+		return newCastingError(err, string(rawValue), structField)
 	}
 	if isPointer {
 		field.Set(reflect.ValueOf(&t))
 	} else {
-		field.Set(reflect.ValueOf(t))
+		*field.Addr().Interface().(*time.Time) = t
 	}
 	return nil
 }
 
+// getTimeFormat returns the time layout set by the format tag of the field or time.RFC3339 by default.
+func getTimeFormat(field *reflect.StructField) string {
+	if field != nil {
+		if timeFormat, ok := field.Tag.Lookup(format); ok {
+			return timeFormat
+		}
+	}
+	return time.RFC3339
+}
+
 func newCastingError(err error, rawValue string, structField *reflect.StructField) error {
 	return fmt.Errorf(`filed casting "%s" to "%s:%v": %w`, rawValue, structField.Name, structField.Type, err)
 }
@@ -320,36 +796,48 @@ func newOverflowError(value any, structField *reflect.StructField) error {
 	return fmt.Errorf(`value %v is too big for field %s:%v`, value, structField.Name, structField.Type)
 }
 
-func getColumns(sType reflect.Type) []string {
-	fCount := sType.NumField()
-	columnNames := make([]string, 0, fCount)
-	for i := range fCount {
-		field := sType.Field(i)
-		column := getRefName(&field)
-		columnNames = append(columnNames, column)
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+// compileHeaderPatterns compiles the patterns locating the columns in the header line, names[i] are the accepted
+// names of the column i. Lenient patterns ignore differences in case and whitespace.
+func compileHeaderPatterns(names [][]string, lenient bool) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, len(names))
+	for i, columnNames := range names {
+		// the longest name wins if one name is a prefix of another
+		alternatives := make([]string, len(columnNames))
+		for j, name := range columnNames {
+			alternatives[j] = regexp.QuoteMeta(name)
+		}
+		slices.SortStableFunc(alternatives, func(a, b string) int { return len(b) - len(a) })
+		expr := strings.Join(alternatives, "|")
+		if lenient {
+			expr = "(?i)" + whitespaceRun.ReplaceAllString(expr, `\s*`)
+		}
+		re, err := regexp.Compile(fmt.Sprintf("((?:%s) *)", expr))
+		if err != nil {
+			return nil, fmt.Errorf("%s column parsing error: %w", columnNames[0], err)
+		}
+		patterns[i] = re
 	}
-	return columnNames
+	return patterns, nil
 }
 
-func parseHeaders(headerLine string, columnNames []string) ([]fwColumn, error) {
+// parseHeaders locates the columns in the header line. Positions are counted in characters.
+func parseHeaders(headerLine string, columnNames []string, patterns []*regexp.Regexp) []fwColumn {
 	columns := make([]fwColumn, 0, len(columnNames))
-	for i := range columnNames {
-		colName := columnNames[i]
-		re, err := regexp.Compile(fmt.Sprintf("(%s *)", colName))
-		if err != nil {
-			return nil, fmt.Errorf("%s column parsing error: %w", colName, err)
-		}
-
-		loc := re.FindStringIndex(headerLine)
+	for i, colName := range columnNames {
+		loc := patterns[i].FindStringIndex(headerLine)
 		if loc == nil {
 			continue
 		}
+		start := utf8.RuneCountInString(headerLine[:loc[0]])
 		col := fwColumn{
-			name:  colName,
-			start: loc[0],
-			end:   loc[1],
+			name:   colName,
+			header: strings.TrimRight(headerLine[loc[0]:loc[1]], " "),
+			start:  start,
+			end:    start + utf8.RuneCountInString(headerLine[loc[0]:loc[1]]),
 		}
 		columns = append(columns, col)
 	}
-	return columns, nil
+	return columns
 }
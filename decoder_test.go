@@ -1,11 +1,15 @@
 package fwencoder
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf16"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -221,10 +225,10 @@ func TestIncorrectInput(t *testing.T) {
 		assert.Contains(t, err.Error(), "error in line 2: can't unmarshal")
 	}
 
-	err = Unmarshal([]byte("Float32\nhello  "), &([]B{}))
-	if assert.Error(t, err) {
-		assert.Contains(t, err.Error(), "error parsing regexp")
-	}
+	// column names are matched literally
+	var obtained []B
+	require.NoError(t, Unmarshal([]byte(")Float32\n42      "), &obtained))
+	assert.Equal(t, []B{{Int: 42}}, obtained)
 }
 
 func TestPtrFieldsOverflow(t *testing.T) {
@@ -262,3 +266,364 @@ func TestPtrFieldsOverflow(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshal_LineLength(t *testing.T) {
+	type Person struct {
+		Name    string
+		Address string
+	}
+
+	data := []byte("Name  Address   \n" +
+		"John  Main st.  \n" +
+		"Jane  Oak\n" +
+		"Bob   Elm street 12")
+
+	var obtained []Person
+	err := Unmarshal(data, &obtained)
+	require.EqualError(t, err, "wrong data length in line 3: expected 16 characters, got 9")
+
+	err = Unmarshal(data, &obtained, WithPadShortLines())
+	require.EqualError(t, err, "wrong data length in line 4: expected 16 characters, got 19")
+
+	err = Unmarshal(data, &obtained, WithPadShortLines(), WithTruncateLongLines())
+	require.NoError(t, err)
+	assert.Equal(t, []Person{{"John", "Main st."}, {"Jane", "Oak"}, {"Bob", "Elm street"}}, obtained)
+
+	err = Unmarshal(data, &obtained, WithOpenEndedLastColumn())
+	require.NoError(t, err)
+	assert.Equal(t, []Person{{"John", "Main st."}, {"Jane", "Oak"}, {"Bob", "Elm street 12"}}, obtained)
+
+	err = Unmarshal([]byte("Name  Address   \nJo"), &obtained, WithOpenEndedLastColumn())
+	require.EqualError(t, err, "wrong data length in line 2: expected 16 characters, got 2")
+
+	err = Unmarshal([]byte("Name  Address   \nJo"), &obtained, WithOpenEndedLastColumn(), WithPadShortLines())
+	require.NoError(t, err)
+	assert.Equal(t, []Person{{"Jo", ""}}, obtained)
+}
+
+func TestUnmarshal_LineEndingsAndBOM(t *testing.T) {
+	type Person struct {
+		Name string
+		City string
+	}
+	expected := []Person{{"John", "Berlin"}, {"Jürgen", "Köln 🏠"}}
+	lines := []string{"Name   City    ", "John   Berlin  ", "Jürgen Köln 🏠  "}
+
+	utf16Encode := func(s string, order binary.AppendByteOrder, bom []byte) []byte {
+		b := append([]byte{}, bom...)
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = order.AppendUint16(b, u)
+		}
+		return b
+	}
+
+	inputs := map[string][]byte{
+		"LF":         []byte(strings.Join(lines, "\n") + "\n"),
+		"CRLF":       []byte(strings.Join(lines, "\r\n") + "\r\n"),
+		"CR":         []byte(strings.Join(lines, "\r")),
+		"mixed":      []byte(lines[0] + "\r\n" + lines[1] + "\r" + lines[2] + "\n"),
+		"UTF-8 BOM":  append([]byte{0xEF, 0xBB, 0xBF}, strings.Join(lines, "\r\n")...),
+		"UTF-16LE":   utf16Encode(strings.Join(lines, "\r\n"), binary.LittleEndian, []byte{0xFF, 0xFE}),
+		"UTF-16BE":   utf16Encode(strings.Join(lines, "\n"), binary.BigEndian, []byte{0xFE, 0xFF}),
+		"empty line": []byte(lines[0] + "\r\n" + lines[1] + "\r\n" + lines[2] + "\r\n"),
+	}
+
+	for name, input := range inputs {
+		var obtained []Person
+		if assert.NoError(t, Unmarshal(input, &obtained), name) {
+			assert.Equal(t, expected, obtained, name)
+		}
+	}
+
+	var obtained []Person
+	input := utf16Encode(strings.Join(lines, "\n")+"\n", binary.LittleEndian, []byte{0xFF, 0xFE})
+	err := Unmarshal(input[:len(input)-1], &obtained)
+	require.ErrorIs(t, err, ErrTruncatedUTF16)
+}
+
+func TestUnmarshal_Separator(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+	people := []Person{{Name: "John", Age: 20}, {Name: "Alexander", Age: 5}}
+
+	for _, separator := range []string{"", " ", "|", " | ", "   "} {
+		b, err := Marshal(&people, WithSeparator(separator), WithFinalNewline(), WithCRLF())
+		require.NoError(t, err)
+
+		var obtained []Person
+		require.NoError(t, Unmarshal(b, &obtained, WithSeparator(separator)), separator)
+		assert.Equal(t, people, obtained, separator)
+
+		if separator == "" {
+			continue
+		}
+		var records []map[string]string
+		require.NoError(t, Unmarshal(b, &records, WithSeparator(separator)), separator)
+		assert.Equal(t, []map[string]string{{"Name": "John", "Age": "20"}, {"Name": "Alexander", "Age": "5"}}, records, separator)
+	}
+}
+
+func TestUnmarshal_Preamble(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+	expected := []Person{{"John", 20}, {"Jane", 30}}
+
+	data := []byte("Monthly report\n" +
+		"Run date: 2024-01-31\n" +
+		"\n" +
+		"Name Age\n" +
+		"# adults only\n" +
+		"John 20 \n" +
+		"\n" +
+		"// TODO: verify\n" +
+		"Jane 30 ")
+
+	var obtained []Person
+	err := Unmarshal(data, &obtained, WithSkipLines(3), WithSkipBlankLines(), WithCommentPrefix("#", "//"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, obtained)
+
+	err = Unmarshal(data, &obtained, WithSkipBlankLines(), WithCommentPattern(regexp.MustCompile(`^(#|//)`)), WithHeaderSearch(5))
+	require.NoError(t, err)
+	assert.Equal(t, expected, obtained)
+
+	err = Unmarshal(data, &obtained, WithHeaderSearch(3))
+	require.ErrorIs(t, err, ErrHeaderNotFound)
+
+	err = Unmarshal([]byte("Title\nName Age"), &obtained, WithHeaderSearch(3))
+	require.NoError(t, err)
+	assert.Empty(t, obtained)
+
+	err = Unmarshal([]byte("Title\nName"), &obtained, WithHeaderSearch(3))
+	require.ErrorIs(t, err, ErrHeaderNotFound)
+
+	err = Unmarshal(data, &obtained, WithSkipLines(3))
+	require.EqualError(t, err, "wrong data length in line 5: expected 8 characters, got 13")
+
+	var records []Record
+	err = Unmarshal(data, &records, WithHeaderSearch(5))
+	require.EqualError(t, err, "header search requires a struct or a layout")
+	layout := &Layout{Columns: []Column{{Name: "Name", Start: 0, Width: 5}, {Name: "Age", Start: 5, Width: 3}}}
+	err = Unmarshal(data, &records, WithSkipBlankLines(), WithCommentPrefix("#", "//"), WithHeaderSearch(5), WithLayout(layout))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "Jane", records[1].String("Name"))
+}
+
+func TestUnmarshal_HeaderAliases(t *testing.T) {
+	type Address struct {
+		City string `fw:"Stadt,alias=City|Town"`
+		Zip  string `fw:"Zip,alias=ZIP CODE|Postcode"`
+	}
+
+	var obtained []Address
+	require.NoError(t, Unmarshal([]byte("City   ZIP CODE\nBerlin 10115   "), &obtained))
+	assert.Equal(t, []Address{{City: "Berlin", Zip: "10115"}}, obtained)
+
+	matched := map[string]string{}
+	data := []byte("Straße town  zipcode\nx      Köln  50667  ")
+	err := Unmarshal(data, &obtained, WithLenientHeaders(), WithHeaderMatchFunc(func(column, header string) {
+		matched[column] = header
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []Address{{City: "Köln", Zip: "50667"}}, obtained)
+	assert.Equal(t, map[string]string{"Stadt": "town", "Zip": "zipcode"}, matched)
+
+	require.NoError(t, Unmarshal(data, &obtained))
+	assert.Equal(t, []Address{{}}, obtained)
+
+	type Payment struct {
+		Amount string `fw:"Amt(USD)"`
+		Zip    string `fw:"Postcode,alias=Zip (US)|Zip.Code"`
+	}
+	var payments []Payment
+	require.NoError(t, Unmarshal([]byte("Amt(USD) Zip (US)\n12.50    10115   "), &payments))
+	assert.Equal(t, []Payment{{Amount: "12.50", Zip: "10115"}}, payments)
+	require.NoError(t, Unmarshal([]byte("amt(usd) zip  (us)\n12.50    10115    "), &payments, WithLenientHeaders()))
+	assert.Equal(t, []Payment{{Amount: "12.50", Zip: "10115"}}, payments)
+	require.NoError(t, Unmarshal([]byte("AmtUSD ZipxCode\n12.50  10115   "), &payments))
+	assert.Equal(t, []Payment{{}}, payments)
+
+	type Misspelled struct {
+		Zip string `fw:"Zip,alais=Postcode"`
+	}
+	err = Unmarshal([]byte("Postcode\n10115   "), &[]Misspelled{})
+	require.EqualError(t, err, `field Zip: invalid fw tag option "alais=Postcode": unknown option`)
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data, err := os.ReadFile("./testdata/correct_all_supported.txt")
+	require.NoError(b, err)
+	header, row, _ := strings.Cut(strings.TrimRight(string(data), "\n"), "\n")
+
+	for _, rows := range []int{1, 1000} {
+		data := []byte(header + strings.Repeat("\n"+row, rows))
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				var items []TestStruct
+				if err := Unmarshal(data, &items); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshal_StrictHeaders(t *testing.T) {
+	type Address struct {
+		City string `fw:",required"`
+		Zip  string `fw:",alias=Postcode|City Zip"`
+	}
+
+	var obtained []Address
+	require.NoError(t, Unmarshal([]byte("City   Zip  \nBerlin 10115"), &obtained, WithStrictHeaders()))
+	assert.Equal(t, []Address{{City: "Berlin", Zip: "10115"}}, obtained)
+
+	require.NoError(t, Unmarshal([]byte("City   Country\nBerlin DE     "), &obtained))
+	assert.Equal(t, []Address{{City: "Berlin"}}, obtained)
+	require.EqualError(t, Unmarshal([]byte("Zip  \n10115"), &obtained), "invalid header: missing column City")
+
+	tests := map[string]string{
+		"City   Country\nBerlin DE     ":               "invalid header: missing column Zip",
+		"City   Zip   Country\nBerlin 10115 DE     ":   "invalid header: unknown column Country",
+		"City   Zip   Postcode\nBerlin 10115 10115   ": "invalid header: duplicate column Zip",
+		"City Zip\nBerlin 1":                           "invalid header: columns City and Zip overlap",
+	}
+	for data, expected := range tests {
+		err := Unmarshal([]byte(data), &obtained, WithStrictHeaders())
+		require.ErrorIs(t, err, ErrInvalidHeader, data)
+		require.EqualError(t, err, expected, data)
+	}
+
+	var records []Record
+	err := Unmarshal([]byte("Name Name\nA    B   "), &records, WithStrictHeaders())
+	require.EqualError(t, err, "invalid header: duplicate column Name")
+
+	type Misspelled struct {
+		City string `fw:",requried"`
+	}
+	err = Unmarshal([]byte("Zip  \n10115"), &[]Misspelled{})
+	require.EqualError(t, err, `field City: invalid fw tag option "requried": unknown option`)
+}
+
+func TestExtraColumns(t *testing.T) {
+	type Person struct {
+		Name  string
+		Extra map[string]string `fw:",extra"`
+	}
+	type OrderedPerson struct {
+		Name  string
+		Extra Record `fw:",extra"`
+	}
+	data := []byte("Zone Name Age\nEU   John 42 \nUS   Jane    ")
+
+	var people []Person
+	require.NoError(t, Unmarshal(data, &people, WithStrictHeaders()))
+	assert.Equal(t, []Person{
+		{Name: "John", Extra: map[string]string{"Zone": "EU", "Age": "42"}},
+		{Name: "Jane", Extra: map[string]string{"Zone": "US", "Age": ""}},
+	}, people)
+
+	b, err := Marshal(&people)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(b))
+
+	type Customer struct {
+		Name  string
+		Age   string
+		Extra map[string]string `fw:",extra"`
+	}
+	customers := []Customer{{Name: "John", Age: "42", Extra: map[string]string{"Zip": "10115", "City": "Berlin"}}}
+	b, err = Marshal(&customers)
+	require.NoError(t, err)
+	assert.Equal(t, "Name Age City   Zip  \nJohn 42  Berlin 10115", string(b))
+
+	customerData := []byte("Name Zip   Age City  \nJohn 10115 42  Berlin\nJane 50667     Köln  ")
+	require.NoError(t, Unmarshal(customerData, &customers))
+	customers = append(customers, Customer{Name: "Bob", Extra: map[string]string{"Zip": "1", "Team": "A"}})
+	b, err = Marshal(&customers)
+	require.NoError(t, err)
+	assert.Equal(t, "Name Zip   Age City   Team\n"+
+		"John 10115 42  Berlin     \n"+
+		"Jane 50667     Köln       \n"+
+		"Bob  1                A   ", string(b))
+
+	var ordered []OrderedPerson
+	require.NoError(t, Unmarshal(data, &ordered))
+	assert.Equal(t, []string{"Zone", "Age"}, ordered[0].Extra.Columns())
+	assert.Equal(t, "42", ordered[0].Extra.String("Age"))
+
+	b, err = Marshal(&ordered)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(b))
+
+	type Between struct {
+		Name  string
+		Age   string
+		Extra Record `fw:",extra"`
+	}
+	data = []byte("Name Zone Team Age\nJohn EU   A    42 ")
+	var between []Between
+	require.NoError(t, Unmarshal(data, &between))
+	b, err = Marshal(&between)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(b))
+
+	type Invalid struct {
+		Extra []string `fw:",extra"`
+	}
+	expected := "field Extra: extra columns require map[string]string or fwencoder.Record, got []string"
+	require.EqualError(t, Unmarshal(data, &[]Invalid{}), expected)
+	_, err = Marshal(&[]Invalid{})
+	require.EqualError(t, err, expected)
+}
+
+func TestNullValues(t *testing.T) {
+	type Row struct {
+		Count   *int
+		Name    *string    `fw:",null=\\N"`
+		Score   int        `fw:",null=NULL|-"`
+		Updated *time.Time `fw:",nullzeros" format:"20060102"`
+		Age     int
+	}
+
+	rows := []Row{{}}
+	b, err := Marshal(&rows)
+	require.NoError(t, err)
+	assert.Equal(t, "Count Name Score Updated Age\n      \\N   0             0  ", string(b))
+
+	var obtained []Row
+	require.NoError(t, Unmarshal(b, &obtained))
+	assert.Equal(t, rows, obtained)
+
+	type Padded struct {
+		Balance *int `fw:",width=8,align=right,pad=0"`
+		Limit   *int `fw:",width=8,align=right,pad=0,null=NULL"`
+	}
+	padded := []Padded{{}}
+	b, err = Marshal(&padded)
+	require.NoError(t, err)
+	assert.Equal(t, "Balance  Limit   \n             NULL", string(b))
+	var obtainedPadded []Padded
+	require.NoError(t, Unmarshal(b, &obtainedPadded))
+	assert.Equal(t, padded, obtainedPadded)
+
+	layout, err := LayoutOf(padded)
+	require.NoError(t, err)
+	b, err = Marshal(&padded, WithLayout(layout), WithoutHeader())
+	require.NoError(t, err)
+	assert.Equal(t, "            NULL", string(b))
+	require.NoError(t, Unmarshal(b, &obtainedPadded, WithLayout(layout), WithoutHeader()))
+	assert.Equal(t, padded, obtainedPadded)
+
+	data := []byte("Count Name Score Updated  Age\n7     John NULL  00000000    ")
+	require.ErrorContains(t, Unmarshal(data, &obtained), `filed casting "" to "Age:int"`)
+	require.NoError(t, Unmarshal(data, &obtained, WithEmptyAsZero()))
+	count, name := 7, "John"
+	assert.Equal(t, []Row{{Count: &count, Name: &name}}, obtained)
+}
@@ -1,39 +1,37 @@
 package fwencoder
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
-type columnWidthMap map[string]uint64
-
-func (c columnWidthMap) Set(name string, width uint64) {
-	old, ok := c[name]
-	if !ok {
-		c[name], old = uint64(len([]rune(name))), uint64(len([]rune(name)))
-	}
-
-	if old < width {
-		c[name] = width
-	}
-}
-
 // Marshal returns the fixed width table data encoding of v
 // If v is nil or not a pointer to slice of structs, Unmarshal returns an ErrIncorrectInputValue.
 //
 // By default, Marshal converts struct's field names to column names. This behavior could be
-// overridden by `column` or `json` tags.
+// overridden by `column` or `json` tags. Unexported fields and fields with the column name "-" are ignored.
+// Zero values of fields with the omitempty option of the `json` or `fw` tag are written as empty cells.
 //
 // To unmarshal raw data into a struct, Unmarshal tries to convert every column's data from string to
 // Marshal converts base go types into their string representation (int, int8, int16, int32, int64, uint, uint8, uint16,
 // uint32, uint64, float32, float64, string, bool, time.Time)
 // It also supports slices and custom types by converting them to JSON.
+// Floats are written in the shortest representation, the `fw` tag can set the number of decimal places with prec
+// and the notation with the fixed and exp flags, e.g. `fw:"prec=2"`.
+// Columns are as wide as their widest value, the `fw` tag can set the exact width, alignment and pad character,
+// e.g. `fw:",width=10,align=right,pad=0"`. A width narrower than the column name is an error unless WithoutHeader is set.
+// Values wider than the width are reported with ErrValueTooLong unless the overflow option cuts them,
+// e.g. `fw:",width=10,overflow=ellipsis"`.
 //
 // By default, time.RFC3339 is used to parse time.Time data. To override this behavior use `format` tag.
 // For example:
@@ -43,14 +41,24 @@ func (c columnWidthMap) Set(name string, width uint64) {
 //	    BDate    time.Time `column:"Birthday" format:"2006/01/02"`
 //	    Postcode int       `json:"Zip"`
 //	}
-func Marshal(v any) ([]byte, error) {
+//
+// v can also be a pointer to a slice of maps with string keys or a slice of Record. Maps are written with columns
+// sorted by name, records with columns in order of appearance. Use WithColumns to set the columns explicitly.
+//
+// Column positions can be set explicitly with the WithLayout option, e.g. to produce data without the header line.
+// The table layout can be changed with the WithTableStyle option, e.g. to render an ASCII or Markdown table.
+// A trailer line with the record count and control totals is written after the data if WithTrailer option is set.
+// Lines are separated with \n, use WithCRLF or WithLineTerminator to change it, WithFinalNewline to terminate
+// the last line and WithBOM to prepend a UTF-8 byte order mark. Columns of plain tables are separated with a single
+// space unless WithSeparator is set, WithoutHeader omits the header line.
+func Marshal(v any, opts ...EncoderOption) ([]byte, error) {
 	buf := bytes.Buffer{}
-	err := MarshalWriter(&buf, v)
+	err := MarshalWriter(&buf, v, opts...)
 	return buf.Bytes(), err
 }
 
 // MarshalWriter behaves the same as Marshal, but write data into io.Writer
-func MarshalWriter(writer io.Writer, v any) (err error) {
+func MarshalWriter(writer io.Writer, v any, opts ...EncoderOption) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -59,230 +67,602 @@ func MarshalWriter(writer io.Writer, v any) (err error) {
 			err = r.(error)
 		}
 	}()
-	sliceItemType := reflect.TypeOf(v)
-	if sliceItemType != nil && sliceItemType.Kind() == reflect.Ptr {
-		sliceItemType = sliceItemType.Elem()
-	} else {
-		return ErrIncorrectInputValue
+	options := newEncoderOptions(opts)
+	if err := options.validate(); err != nil {
+		return err
 	}
 
-	if sliceItemType.Kind() == reflect.Slice {
-		sliceItemType = sliceItemType.Elem()
-	} else {
-		return ErrIncorrectInputValue
+	columns := options.columns
+	if options.layout != nil {
+		columns = options.layout.Names()
+	}
+	source, err := newEncodeSource(v, columns)
+	if err != nil {
+		return err
 	}
 
-	slice := reflect.ValueOf(v)
-	if slice.Kind() == reflect.Ptr {
-		slice = slice.Elem()
+	if buf, ok := writer.(*bytes.Buffer); ok {
+		// writes into memory don't need buffering
+		return encode(buf, source, options)
+	}
+	buffered := bufio.NewWriter(writer)
+	if err := encode(buffered, source, options); err != nil {
+		return err
 	}
+	return buffered.Flush()
+}
 
-	sliceType := sliceItemType
-	if sliceType.Kind() == reflect.Ptr {
-		sliceType = sliceType.Elem()
+func encode(writer io.Writer, source encodeSource, options *encoderOptions) error {
+	if options.bom {
+		if _, err := writer.Write(utf8BOM); err != nil {
+			return err
+		}
 	}
 
-	if sliceType.Kind() != reflect.Struct {
-		return ErrIncorrectInputValue
+	lines := &lineWriter{writer: writer, terminator: options.lineTerminator}
+	var err error
+	if options.layout != nil {
+		err = writeLayoutData(lines, source, options)
+	} else {
+		err = writeTable(lines, source, options)
+	}
+	if err != nil {
+		return err
 	}
 
-	columnNames := getColumns(sliceType)
-	columnWidthIndex, err := makeColumnWidthIndex(slice, columnNames)
+	if options.trailer != nil {
+		trailerLine, err := options.trailer.render(source)
+		if err != nil {
+			return err
+		}
+		if err := lines.writeLine([]byte(trailerLine)); err != nil {
+			return err
+		}
+	}
+	if options.finalNewline && lines.started {
+		_, err := io.WriteString(writer, options.lineTerminator)
+		return err
+	}
+	return nil
+}
+
+// lineWriter writes lines separated by the line terminator. The last line is terminated only with WithFinalNewline.
+type lineWriter struct {
+	writer     io.Writer
+	terminator string
+	started    bool
+}
+
+func (w *lineWriter) writeLine(line []byte) error {
+	if w.started {
+		if _, err := io.WriteString(w.writer, w.terminator); err != nil {
+			return err
+		}
+	}
+	w.started = true
+	_, err := w.writer.Write(line)
+	return err
+}
+
+func writeTable(lines *lineWriter, source encodeSource, options *encoderOptions) error {
+	markdown := options.style == StyleMarkdown
+	columns, err := newTableColumns(source, options.header, markdown)
+	if err != nil {
+		return err
+	}
+	cells, err := renderCells(source, columns, markdown)
 	if err != nil {
 		return err
 	}
+	if markdown {
+		for i := range columns {
+			columns[i].width = max(columns[i].width, markdownMinWidth)
+		}
+	}
 
-	if err := writeHeader(writer, columnNames, columnWidthIndex); err != nil {
+	if err := writeHeader(lines, columns, options); err != nil {
 		return err
 	}
+	return writeData(lines, columns, cells, options)
+}
 
-	return writeData(writer, slice, columnWidthIndex)
+// encodeSource provides the cells of the value passed to MarshalWriter.
+type encodeSource interface {
+	trailerSource
+	columnNames() []string
+	// columnField returns the plan of the struct field mapped to the column, nil for dynamic sources.
+	columnField(column int) *fieldPlan
+	// cell returns the value of the column in the row and the plan of the struct field the value is read from.
+	// The field is nil for dynamic sources, the value is invalid if the cell is empty.
+	cell(row, column int) (reflect.Value, *fieldPlan, error)
 }
 
-func writeData(writer io.Writer, slice reflect.Value, columnWidthIndex columnWidthMap) error {
-	for i := range slice.Len() {
-		item := slice.Index(i)
-		if item.Kind() == reflect.Ptr {
-			item = item.Elem()
+func newEncodeSource(v any, columns []string) (encodeSource, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return nil, ErrIncorrectInputValue
+	}
+	slice := reflect.ValueOf(v).Elem()
+	itemType := t.Elem().Elem()
+
+	switch {
+	case itemType == recordType:
+		return newRecordEncodeSource(slice, columns), nil
+	case itemType.Kind() == reflect.Map && itemType.Key().Kind() == reflect.String:
+		return newMapEncodeSource(slice, columns), nil
+	}
+
+	if itemType.Kind() == reflect.Ptr {
+		itemType = itemType.Elem()
+	}
+	if itemType.Kind() != reflect.Struct {
+		return nil, ErrIncorrectInputValue
+	}
+	return newStructEncodeSource(slice, itemType, columns)
+}
+
+type structEncodeSource struct {
+	slice   reflect.Value
+	columns []string
+	fields  []*fieldPlan // fields[i] is mapped to columns[i], nil for the columns of the extra field
+	extra   *fieldPlan
+
+	// marshal is set if the items implement FixedWidthMarshaler, the cells of the last marshaled row are cached
+	marshal   bool
+	cachedRow int
+	text      []byte
+	ends      []int // ends[i] is the end offset of the cell of the i-th struct field in text
+}
+
+func newStructEncodeSource(slice reflect.Value, itemType reflect.Type, columns []string) (*structEncodeSource, error) {
+	plan := planOf(itemType)
+	if plan.extra != nil && plan.extra.tagErr != nil {
+		return nil, plan.extra.tagErr
+	}
+	if columns == nil {
+		columns = plan.columns
+		if plan.extra != nil {
+			columns = withExtraColumns(slice, plan)
 		}
-		fieldsCount := item.NumField()
-		for fieldIndex := range fieldsCount {
-			fieldValue := item.Field(fieldIndex)
-			fieldInfo := item.Type().Field(fieldIndex)
-			refName := getRefName(&fieldInfo)
-			columnWidth := columnWidthIndex[refName]
-			if err := writeValue(writer, fieldValue, &fieldInfo, columnWidth); err != nil {
-				return err
-			}
-			if fieldIndex != fieldsCount-1 {
-				if _, err := writer.Write([]byte(" ")); err != nil {
-					return err
-				}
-			}
+	}
+	fields := make([]*fieldPlan, len(columns))
+	for i, c := range columns {
+		fieldIndex, ok := plan.index[c]
+		if !ok && plan.extra != nil {
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w %s", ErrUnknownColumn, c)
 		}
+		fields[i] = &plan.fields[fieldIndex]
+		if fields[i].tagErr != nil {
+			return nil, fields[i].tagErr
+		}
+	}
+	return &structEncodeSource{
+		slice:     slice,
+		columns:   columns,
+		fields:    fields,
+		extra:     plan.extra,
+		marshal:   plan.marshal,
+		cachedRow: -1,
+	}, nil
+}
 
-		if i != slice.Len()-1 {
-			if _, err := writer.Write([]byte("\n")); err != nil {
-				return err
-			}
+func (s *structEncodeSource) columnNames() []string {
+	return s.columns
+}
+
+func (s *structEncodeSource) columnField(column int) *fieldPlan {
+	return s.fields[column]
+}
+
+func (s *structEncodeSource) cell(row, column int) (reflect.Value, *fieldPlan, error) {
+	item := s.slice.Index(row)
+	if item.Kind() == reflect.Ptr {
+		if item.IsNil() {
+			return reflect.Value{}, s.fields[column], nil
 		}
+		item = item.Elem()
 	}
-	return nil
+	if s.fields[column] == nil {
+		return s.extraCell(item, column), nil, nil
+	}
+	if !s.marshal {
+		field := s.fields[column]
+		value := item.Field(field.Index[0])
+		if field.omitEmpty && value.IsZero() {
+			return reflect.Value{}, field, nil
+		}
+		return value, field, nil
+	}
+	text, err := s.marshaledCell(item, row, column)
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return reflect.ValueOf(string(text)), s.fields[column], nil
 }
 
-func writeHeader(writer io.Writer, columnNames []string, columnWidthIndex columnWidthMap) error {
-	for i, c := range columnNames {
-		if _, err := fmt.Fprintf(writer, "%-"+strconv.FormatUint(columnWidthIndex[c], 10)+"s", c); err != nil {
-			return err
+// marshaledCell returns the text of the struct column rendered by MarshalFixedWidth of the item.
+func (s *structEncodeSource) marshaledCell(item reflect.Value, row, column int) ([]byte, error) {
+	if s.cachedRow != row {
+		text, ends, err := item.Addr().Interface().(FixedWidthMarshaler).MarshalFixedWidth(s.text[:0], s.ends[:0])
+		if err != nil {
+			return nil, err
+		}
+		if len(ends) != item.NumField() {
+			return nil, fmt.Errorf("MarshalFixedWidth of %v returned %d cells for %d fields",
+				item.Type(), len(ends), item.NumField())
 		}
-		if i != len(columnNames)-1 {
-			if _, err := writer.Write([]byte(" ")); err != nil {
-				return err
+		s.cachedRow, s.text, s.ends = row, text, ends
+	}
+	i := s.fields[column].Index[0]
+	start := 0
+	if i > 0 {
+		start = s.ends[i-1]
+	}
+	return s.text[start:s.ends[i]], nil
+}
+
+// appendCell appends the text of the cell to buf. Marshaled cells are copied, other cells are rendered
+// by appendValue.
+func appendCell(buf []byte, source encodeSource, row, column int) ([]byte, *fieldPlan, error) {
+	if s, ok := source.(*structEncodeSource); ok && s.marshal && s.fields[column] != nil {
+		item := s.slice.Index(row)
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				return buf, s.fields[column], nil
 			}
+			item = item.Elem()
 		}
+		text, err := s.marshaledCell(item, row, column)
+		return append(buf, text...), s.fields[column], err
 	}
-	if _, err := writer.Write([]byte("\n")); err != nil {
-		return err
+	value, field, err := source.cell(row, column)
+	if err != nil {
+		return buf, nil, err
 	}
-	return nil
+	buf, err = appendValue(buf, value, field)
+	return buf, field, err
+}
+
+// extraCell returns the value of the column kept by the extra field, the value is invalid if there is no such column.
+func (s *structEncodeSource) extraCell(item reflect.Value, column int) reflect.Value {
+	extra := item.Field(s.extra.Index[0])
+	if extra.Type() == recordType {
+		value, ok := extra.Interface().(Record).Get(s.columns[column])
+		if !ok {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(value)
+	}
+	return extra.MapIndex(reflect.ValueOf(s.columns[column]))
 }
 
-func makeColumnWidthIndex(slice reflect.Value, columnNames []string) (columnWidthMap, error) {
-	columnWidthIndex := make(columnWidthMap, len(columnNames))
+// withExtraColumns returns the struct columns and the columns of the extra fields which aren't struct columns.
+// Decoded records put their columns back to the positions of the header line they were read from, decoded maps
+// to the positions of the last header line maps of the type were read from. Other extra columns follow the struct
+// columns: records keep the order of their columns, map keys are sorted by name.
+func withExtraColumns(slice reflect.Value, plan *typePlan) []string {
+	var names, line []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if _, ok := plan.index[name]; !ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
 	for i := range slice.Len() {
 		item := slice.Index(i)
-
 		if item.Kind() == reflect.Ptr {
 			if item.IsNil() {
 				continue
 			}
 			item = item.Elem()
 		}
-
-		fieldsCount := item.NumField()
-		for fieldIndex := range fieldsCount {
-			currentField := item.Field(fieldIndex)
-			typeField := item.Type().Field(fieldIndex)
-			refName := getRefName(&typeField)
-			fieldLen, err := getFieldLen(currentField, &typeField)
-			if err != nil {
-				return nil, err
+		extra := item.Field(plan.extra.Index[0])
+		if extra.Type() == recordType {
+			record := extra.Interface().(Record)
+			if line == nil && record.header != nil {
+				line = record.header.line
 			}
-			columnWidthIndex.Set(refName, fieldLen)
+			for _, name := range record.Columns() {
+				add(name)
+			}
+			continue
+		}
+		for iter := extra.MapRange(); iter.Next(); {
+			add(iter.Key().String())
+		}
+	}
+	if plan.extra.Type == stringMapType {
+		sort.Strings(names)
+		if extraLine := plan.extraLine.Load(); extraLine != nil {
+			line = *extraLine
 		}
 	}
-	return columnWidthIndex, nil
+	return insertLineColumns(slices.Clone(plan.columns), names, line)
 }
 
-//nolint:gocyclo // it's ok
-func writeValue(w io.Writer, value reflect.Value, field *reflect.StructField, width uint64) error {
-	gap := strconv.FormatUint(width, 10)
-
-	if value.Kind() == reflect.Ptr {
-		if value.IsNil() {
-			for range width {
-				if _, err := w.Write([]byte(" ")); err != nil {
-					return err
-				}
+// insertLineColumns inserts the extra columns found in the header line after the column preceding them in the line
+// and appends the others.
+func insertLineColumns(columns, extra, line []string) []string {
+	for i, name := range line {
+		if !slices.Contains(extra, name) || slices.Contains(columns, name) {
+			continue
+		}
+		pos := 0
+		for j := i - 1; j >= 0; j-- {
+			if k := slices.Index(columns, line[j]); k >= 0 {
+				pos = k + 1
+				break
 			}
-			return nil
 		}
-		value = value.Elem()
+		columns = slices.Insert(columns, pos, name)
 	}
-
-	switch value.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if _, err := fmt.Fprintf(w, "%-"+gap+"d", value.Int()); err != nil {
-			return err
+	for _, name := range extra {
+		if !slices.Contains(columns, name) {
+			columns = append(columns, name)
 		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if _, err := fmt.Fprintf(w, "%-"+gap+"d", value.Uint()); err != nil {
-			return err
+	}
+	return columns
+}
+
+func (s *structEncodeSource) recordCount() int {
+	return s.slice.Len()
+}
+
+func (s *structEncodeSource) sumColumn(column string, decimals int) (int64, error) {
+	return sumCells(s, column, decimals)
+}
+
+// tableColumn is a column of the table written with the header line.
+type tableColumn struct {
+	name     string
+	width    int  // the column width in characters
+	fixed    bool // the width is set by the fw tag, otherwise the column fits its values and name
+	align    Alignment
+	pad      rune
+	overflow OverflowPolicy
+	null     string // the null token of the column
+}
+
+// newTableColumns returns the columns of the source with the width, alignment and padding of the fw tags.
+// Columns fit the names if the header line is written, names wider than the fixed width are an error
+// as the decoder couldn't find the column in the header line. Markdown names are escaped.
+func newTableColumns(source encodeSource, header, markdown bool) ([]tableColumn, error) {
+	names := source.columnNames()
+	columns := make([]tableColumn, len(names))
+	for i, name := range names {
+		if markdown {
+			name = markdownEscaper.Replace(name)
 		}
-	case reflect.Float32, reflect.Float64:
-		if _, err := fmt.Fprintf(w, "%-"+gap+"g", value.Float()); err != nil {
-			return err
+		c := &columns[i]
+		c.name, c.pad = name, ' '
+		if header {
+			c.width = utf8.RuneCountInString(name)
 		}
-	case reflect.Bool:
-		if value.Bool() {
-			if _, err := fmt.Fprintf(w, "%-"+gap+"s", "true"); err != nil {
-				return err
-			}
-		} else {
-			if _, err := fmt.Fprintf(w, "%-"+gap+"s", "false"); err != nil {
-				return err
+		if field := source.columnField(i); field != nil && field.tag != nil {
+			c.align, c.pad, c.overflow, c.null = field.tag.align, field.tag.pad, field.tag.overflow, nullToken(field)
+			if field.tag.width > 0 {
+				c.width, c.fixed = field.tag.width, true
 			}
 		}
-	case reflect.String:
-		if _, err := fmt.Fprintf(w, "%-"+gap+"s", value.String()); err != nil {
-			return err
+		if header && c.width < utf8.RuneCountInString(name) {
+			return nil, fmt.Errorf("column %s: %w: the name doesn't fit in %d characters", name, ErrValueTooLong, c.width)
 		}
-	case reflect.Struct:
-		if value.Type() == reflect.TypeOf(time.Time{}) {
-			timeFormat, ok := field.Tag.Lookup(format)
-			if !ok {
-				timeFormat = time.RFC3339
+	}
+	return columns, nil
+}
+
+// renderedCells holds the text of all cells of a table, every cell is rendered once
+// and the column widths are computed from the rendered text.
+type renderedCells struct {
+	text []byte
+	ends []int // ends[row*columns+column] is the end offset of the cell in text
+}
+
+// renderCells renders the cells of the source and widens the columns without a fixed width to fit their values.
+// Markdown cells are escaped, so the values can't break the table.
+func renderCells(source encodeSource, columns []tableColumn, markdown bool) (*renderedCells, error) {
+	rows := source.recordCount()
+	cells := &renderedCells{ends: make([]int, 0, rows*len(columns))}
+	for row := range rows {
+		for i := range columns {
+			c := &columns[i]
+			start := len(cells.text)
+			text, field, err := appendCell(cells.text, source, row, i)
+			if err != nil {
+				return nil, err
+			}
+			cells.text = text
+			if markdown {
+				cells.text = escapeMarkdown(cells.text, start)
 			}
-			if _, err := fmt.Fprintf(w, "%-"+gap+"s", value.Interface().(time.Time).Format(timeFormat)); err != nil {
-				return err
+			width := utf8.RuneCount(cells.text[start:])
+			switch {
+			case !c.fixed:
+				c.width = max(c.width, width)
+			case width > c.width && c.overflow == OverflowError:
+				return nil, fmt.Errorf("item %d: %w", row, newColumnOverflowError(c.name, field, cells.text[start:], c.width))
+			case width > c.width:
+				cells.text = fitValue(cells.text, start, c.width, c.overflow)
 			}
-			return nil
+			cells.ends = append(cells.ends, len(cells.text))
 		}
-		fallthrough
-	default:
-		b, err := json.Marshal(value.Interface())
-		if err != nil {
-			return err
+	}
+	return cells, nil
+}
+
+// cell returns the text of the i-th cell counting row by row.
+func (c *renderedCells) cell(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = c.ends[i-1]
+	}
+	return c.text[start:c.ends[i]]
+}
+
+func writeData(lines *lineWriter, columns []tableColumn, cells *renderedCells, options *encoderOptions) error {
+	border := options.border
+	rowsCount := 0
+	if len(columns) > 0 {
+		rowsCount = len(cells.ends) / len(columns)
+	}
+	var line []byte
+	for row := range rowsCount {
+		line = append(line[:0], border.rowLeft...)
+		for i := range columns {
+			c := &columns[i]
+			text := cells.cell(row*len(columns) + i)
+			line = appendPadded(line, text, c.width, c.align, cellPad(text, c.pad, c.null))
+			if i != len(columns)-1 {
+				line = append(line, border.rowSep...)
+			}
 		}
-		if _, err := fmt.Fprintf(w, "%-"+gap+"s", string(b)); err != nil {
+		line = append(line, border.rowRight...)
+		if err := lines.writeLine(line); err != nil {
 			return err
 		}
 	}
-	return nil
+	return writeBorderLine(lines, border.bottom, columns)
+}
+
+// writeHeader writes the top border and, unless disabled, the column names left aligned with the header separator.
+func writeHeader(lines *lineWriter, columns []tableColumn, options *encoderOptions) error {
+	border := options.border
+	if err := writeBorderLine(lines, border.top, columns); err != nil {
+		return err
+	}
+	if !options.header {
+		return nil
+	}
+	line := []byte(border.rowLeft)
+	for i, c := range columns {
+		line = appendPadded(line, []byte(c.name), c.width, AlignLeft, ' ')
+		if i != len(columns)-1 {
+			line = append(line, border.rowSep...)
+		}
+	}
+	line = append(line, border.rowRight...)
+	if err := lines.writeLine(line); err != nil {
+		return err
+	}
+	return writeBorderLine(lines, border.headerSep, columns)
+}
+
+// appendPadded appends the text padded up to width characters. Zero padding of right aligned values
+// is inserted after the sign.
+func appendPadded(buf, text []byte, width int, align Alignment, pad rune) []byte {
+	n := width - utf8.RuneCount(text)
+	if n <= 0 {
+		return append(buf, text...)
+	}
+	if align == AlignLeft {
+		buf = append(buf, text...)
+		return appendRepeated(buf, pad, n)
+	}
+	if pad == '0' && len(text) > 0 && (text[0] == '-' || text[0] == '+') {
+		buf = append(buf, text[0])
+		text = text[1:]
+	}
+	buf = appendRepeated(buf, pad, n)
+	return append(buf, text...)
+}
+
+func appendRepeated(buf []byte, r rune, n int) []byte {
+	for range n {
+		buf = utf8.AppendRune(buf, r)
+	}
+	return buf
 }
 
-func getFieldLen(value reflect.Value, field *reflect.StructField) (uint64, error) {
-	const (
-		trueLen  = 4
-		falseLen = 5
-	)
+// renderValue returns the text representation of the value as it is written by the encoder.
+func renderValue(value reflect.Value, field *fieldPlan) (string, error) {
+	b, err := appendValue(nil, value, field)
+	return string(b), err
+}
 
+// appendValue appends the text representation of the value to buf. Nil values are rendered as empty text,
+// nil pointers and sql null values as the null token of the fw tag if there is one.
+func appendValue(buf []byte, value reflect.Value, field *fieldPlan) ([]byte, error) {
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
 	if value.Kind() == reflect.Ptr {
 		if value.IsNil() {
-			return 0, nil
+			return appendNull(buf, field), nil
 		}
 		value = value.Elem()
 	}
+	if !value.IsValid() {
+		return buf, nil
+	}
+	if field == nil || field.sqlValue {
+		if b, ok, err := appendSQLValue(buf, value, field); ok {
+			return b, err
+		}
+	}
 
 	switch value.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return uint64(len(strconv.FormatInt(value.Int(), 10))), nil
+		return strconv.AppendInt(buf, value.Int(), 10), nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return uint64(len(strconv.FormatUint(value.Uint(), 10))), nil
+		return strconv.AppendUint(buf, value.Uint(), 10), nil
 	case reflect.Float32, reflect.Float64:
-		return uint64(len(strconv.FormatFloat(value.Float(), 'f', -1, 64))), nil
+		return appendFloat(buf, value, field), nil
 	case reflect.Bool:
-		if value.Bool() {
-			return trueLen, nil
-		} else {
-			return falseLen, nil
-		}
+		return strconv.AppendBool(buf, value.Bool()), nil
 	case reflect.String:
-		return uint64(len(value.String())), nil
+		return append(buf, value.String()...), nil
 	case reflect.Struct:
-		if value.Type() == reflect.TypeOf(time.Time{}) {
-			timeFormat, ok := field.Tag.Lookup(format)
-			if !ok {
-				timeFormat = time.RFC3339
+		if value.Type() == timeType {
+			timeFormat := time.RFC3339
+			if field != nil {
+				timeFormat = getTimeFormat(&field.StructField)
 			}
-			return uint64(len(value.Interface().(time.Time).Format(timeFormat))), nil
-		}
-		fallthrough
-	default:
-		b, err := json.Marshal(value.Interface())
-		if err != nil {
-			return 0, err
+			return value.Interface().(time.Time).AppendFormat(buf, timeFormat), nil
 		}
-		return uint64(len(b)), nil
 	}
+	b, err := json.Marshal(value.Interface())
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, b...), nil
+}
+
+// appendNull appends the null token of the field.
+func appendNull(buf []byte, field *fieldPlan) []byte {
+	return append(buf, nullToken(field)...)
+}
+
+// nullToken returns the text of null values of the field: the first null token of the fw tag, empty by default.
+func nullToken(field *fieldPlan) string {
+	if field != nil && field.tag != nil && len(field.tag.nulls) > 0 {
+		return field.tag.nulls[0]
+	}
+	return ""
+}
+
+// cellPad returns the pad character of the cell. Null cells, empty or holding the null token, are padded
+// with spaces, so zero padding isn't read back as a value.
+func cellPad(text []byte, pad rune, null string) rune {
+	if len(text) == 0 || string(text) == null {
+		return ' '
+	}
+	return pad
+}
+
+// appendFloat appends the float in the format of the fw tag, the shortest representation by default.
+// float32 values are formatted with their own precision, so they don't get the digits of the float64 conversion.
+func appendFloat(buf []byte, value reflect.Value, field *fieldPlan) []byte {
+	bitSize := 64
+	if value.Kind() == reflect.Float32 {
+		bitSize = 32
+	}
+	format, prec := byte('g'), -1
+	if field != nil && field.tag != nil {
+		format, prec = field.tag.float, field.tag.prec
+	}
+	return strconv.AppendFloat(buf, value.Float(), format, prec, bitSize)
 }
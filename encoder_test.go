@@ -2,11 +2,15 @@ package fwencoder
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMarshalWriter(t *testing.T) {
@@ -130,3 +134,363 @@ func marshallErr(i any) error {
 	_, err := Marshal(i)
 	return err
 }
+
+func TestMarshal_TableStyle(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+	people := []Person{{Name: "John", Age: 20}, {Name: "Alexander", Age: 5}}
+
+	tests := []struct {
+		style    TableStyle
+		expected string
+	}{
+		{
+			style: StylePlain,
+			expected: "Name      Age\n" +
+				"John      20 \n" +
+				"Alexander 5  ",
+		},
+		{
+			style: StyleASCII,
+			expected: "+-----------+-----+\n" +
+				"| Name      | Age |\n" +
+				"+-----------+-----+\n" +
+				"| John      | 20  |\n" +
+				"| Alexander | 5   |\n" +
+				"+-----------+-----+",
+		},
+		{
+			style: StyleUnicode,
+			expected: "┌───────────┬─────┐\n" +
+				"│ Name      │ Age │\n" +
+				"├───────────┼─────┤\n" +
+				"│ John      │ 20  │\n" +
+				"│ Alexander │ 5   │\n" +
+				"└───────────┴─────┘",
+		},
+		{
+			style: StyleMarkdown,
+			expected: "| Name      | Age |\n" +
+				"| --------- | --- |\n" +
+				"| John      | 20  |\n" +
+				"| Alexander | 5   |",
+		},
+	}
+
+	for _, tt := range tests {
+		b, err := Marshal(&people, WithTableStyle(tt.style))
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expected, string(b))
+		}
+	}
+
+	b, err := Marshal(&[]Person{}, WithTableStyle(StyleASCII))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "+------+-----+\n| Name | Age |\n+------+-----+\n+------+-----+", string(b))
+	}
+
+	type Escaped struct {
+		Expr string `fw:"a|b"`
+		Note string
+	}
+	b, err = Marshal(&[]Escaped{{Expr: "x|y", Note: "line\nbreak"}}, WithTableStyle(StyleMarkdown))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "| a\\|b | Note       |\n| ---- | ---------- |\n| x\\|y | line break |", string(b))
+	}
+
+	_, err = Marshal(&people, WithTableStyle(TableStyle(100)))
+	assert.ErrorIs(t, err, ErrUnknownTableStyle)
+}
+
+func TestMarshal_CRLFAndBOM(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+	people := []Person{{Name: "John", Age: 20}, {Name: "Jane", Age: 30}}
+
+	b, err := Marshal(&people, WithCRLF(), WithBOM())
+	require.NoError(t, err)
+	assert.Equal(t, "\xEF\xBB\xBFName Age\r\nJohn 20 \r\nJane 30 ", string(b))
+
+	var obtained []Person
+	require.NoError(t, Unmarshal(b, &obtained))
+	assert.Equal(t, people, obtained)
+}
+
+func TestMarshal_UnicodeWidth(t *testing.T) {
+	type Person struct {
+		Name string
+		City string
+	}
+	people := []Person{{"John", "Berlin"}, {"Jürgen", "Köln 🏠"}}
+
+	b, err := Marshal(&people)
+	require.NoError(t, err)
+	assert.Equal(t, "Name   City  \nJohn   Berlin\nJürgen Köln 🏠", string(b))
+
+	var obtained []Person
+	require.NoError(t, Unmarshal(b, &obtained))
+	assert.Equal(t, people, obtained)
+}
+
+func TestMarshal_FloatFormat(t *testing.T) {
+	type Measurement struct {
+		Value   float64
+		Ratio   float32
+		Amount  float64 `fw:"prec=2"`
+		Big     float64 `fw:",fixed"`
+		Tiny    float64 `fw:"Small,exp,prec=1"`
+		Percent float32 `fw:",prec=1"`
+	}
+	items := []Measurement{
+		{Value: 1e21, Ratio: 0.1, Amount: 3.14159, Big: 1e21, Tiny: 0.0000001, Percent: 12.34},
+		{Value: 0.0000001, Ratio: 1.5, Amount: -2, Big: 0.5, Tiny: 12345, Percent: 5},
+	}
+
+	b, err := Marshal(&items)
+	require.NoError(t, err)
+	assert.Equal(t, "Value Ratio Amount Big                    Small   Percent\n"+
+		"1e+21 0.1   3.14   1000000000000000000000 1.0e-07 12.3   \n"+
+		"1e-07 1.5   -2.00  0.5                    1.2e+04 5.0    ", string(b))
+
+	var obtained []Measurement
+	require.NoError(t, Unmarshal(b, &obtained))
+	assert.InDelta(t, 3.14, obtained[0].Amount, 1e-9)
+	assert.InDelta(t, 1e21, obtained[0].Big, 1)
+	assert.InEpsilon(t, 0.1, obtained[0].Ratio, 1e-6)
+
+	type Invalid struct {
+		Value float64 `fw:",prec=-1"`
+	}
+	_, err = Marshal(&[]Invalid{{}})
+	assert.EqualError(t, err, `field Value: invalid fw tag option "prec=-1": negative precision`)
+}
+
+func TestMarshal_Alignment(t *testing.T) {
+	type Entry struct {
+		Code    string  `fw:",width=6,pad=*"`
+		Amount  float64 `fw:",width=10,align=right,pad=0,prec=2"`
+		Count   int     `fw:",align=right"`
+		Balance *int    `fw:",width=8,align=right,pad=0"`
+		Memo    string
+	}
+	balance := -42
+	entries := []Entry{
+		{Code: "A1", Amount: -12.5, Count: 7, Balance: &balance, Memo: "first"},
+		{Code: "B22", Amount: 1234.567, Count: 1500, Memo: "second"},
+	}
+
+	b, err := Marshal(&entries)
+	require.NoError(t, err)
+	assert.Equal(t, "Code   Amount     Count Balance  Memo  \n"+
+		"A1**** -000012.50     7 -0000042 first \n"+
+		"B22*** 0001234.57  1500          second", string(b))
+
+	var obtained []Entry
+	require.NoError(t, Unmarshal(b, &obtained))
+	require.Len(t, obtained, 2)
+	assert.Equal(t, "A1", obtained[0].Code)
+	assert.InDelta(t, -12.5, obtained[0].Amount, 1e-9)
+	assert.Equal(t, 1500, obtained[1].Count)
+	assert.Equal(t, &balance, obtained[0].Balance)
+	assert.Nil(t, obtained[1].Balance)
+	assert.Equal(t, "B22", obtained[1].Code)
+	assert.InDelta(t, 1234.57, obtained[1].Amount, 1e-9)
+
+	type Narrow struct {
+		Name string `fw:",width=3"`
+	}
+	_, err = Marshal(&[]Narrow{{"abc"}})
+	require.EqualError(t, err, "column Name: value is too long: the name doesn't fit in 3 characters")
+	require.ErrorIs(t, err, ErrValueTooLong)
+
+	b, err = Marshal(&[]Narrow{{"abc"}}, WithoutHeader())
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+
+	type Short struct {
+		ID   int    `fw:",width=2,align=right"`
+		Name string `fw:",width=4"`
+	}
+	shorts := []Short{{ID: 7, Name: "abc"}}
+	b, err = Marshal(&shorts)
+	require.NoError(t, err)
+	assert.Equal(t, "ID Name\n 7 abc ", string(b))
+	var obtainedShorts []Short
+	require.NoError(t, Unmarshal(b, &obtainedShorts))
+	assert.Equal(t, shorts, obtainedShorts)
+
+	_, err = Marshal(&[]Short{{Name: "abcde"}})
+	assert.EqualError(t, err, `item 0: column Name: value is too long: "abcde" doesn't fit in 4 characters`)
+}
+
+func TestMarshal_Overflow(t *testing.T) {
+	type Row struct {
+		Right    string `fw:",width=5,overflow=truncate"`
+		Left     string `fw:",width=5,overflow=truncate-left"`
+		Ellipsis string `fw:"Ell,width=5,overflow=ellipsis"`
+	}
+	rows := []Row{
+		{Right: "Grüße aus Köln", Left: "Grüße aus Köln", Ellipsis: "Grüße aus Köln"},
+		{Right: "short", Left: "🏠🏠🏠🏠🏠🏠", Ellipsis: "ok"},
+	}
+
+	b, err := Marshal(&rows)
+	require.NoError(t, err)
+	assert.Equal(t, "Right Left  Ell  \nGrüße  Köln Grüß…\nshort 🏠🏠🏠🏠🏠 ok   ", string(b))
+
+	type Strict struct {
+		Code string `fw:"ID,width=3"`
+	}
+	_, err = Marshal(&[]Strict{{"abc"}, {"abcd"}})
+	require.ErrorIs(t, err, ErrValueTooLong)
+	assert.EqualError(t, err, `item 1: column ID (field Code): value is too long: "abcd" doesn't fit in 3 characters`)
+
+	type Invalid struct {
+		Code string `fw:",width=3,overflow=wrap"`
+	}
+	_, err = Marshal(&[]Invalid{{"abc"}})
+	assert.EqualError(t, err, `field Code: invalid fw tag option "overflow=wrap": unknown overflow policy "wrap"`)
+}
+
+func TestMarshal_LineOptions(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+	people := []Person{{Name: "John", Age: 20}, {Name: "Alexander", Age: 5}}
+
+	tests := map[string]struct {
+		opts     []EncoderOption
+		expected string
+	}{
+		"no separator": {
+			opts:     []EncoderOption{WithSeparator("")},
+			expected: "Name     Age\nJohn     20 \nAlexander5  ",
+		},
+		"pipe": {
+			opts:     []EncoderOption{WithSeparator("|")},
+			expected: "Name     |Age\nJohn     |20 \nAlexander|5  ",
+		},
+		"spaced pipe": {
+			opts:     []EncoderOption{WithSeparator(" | ")},
+			expected: "Name      | Age\nJohn      | 20 \nAlexander | 5  ",
+		},
+		"multiple spaces": {
+			opts:     []EncoderOption{WithSeparator("   ")},
+			expected: "Name        Age\nJohn        20 \nAlexander   5  ",
+		},
+		"no header": {
+			opts:     []EncoderOption{WithoutHeader()},
+			expected: "John      20\nAlexander 5 ",
+		},
+		"final newline": {
+			opts:     []EncoderOption{WithFinalNewline(), WithLineTerminator("\r")},
+			expected: "Name      Age\rJohn      20 \rAlexander 5  \r",
+		},
+		"ascii without header": {
+			opts:     []EncoderOption{WithoutHeader(), WithTableStyle(StyleASCII), WithFinalNewline()},
+			expected: "+-----------+----+\n| John      | 20 |\n| Alexander | 5  |\n+-----------+----+\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := Marshal(&people, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(b))
+		})
+	}
+
+	b, err := Marshal(&[]Person{}, WithFinalNewline())
+	require.NoError(t, err)
+	assert.Equal(t, "Name Age\n", string(b))
+
+	b, err = Marshal(&[]Person{}, WithoutHeader(), WithFinalNewline())
+	require.NoError(t, err)
+	assert.Empty(t, b)
+
+	_, err = Marshal(&people, WithLineTerminator("\n\n"))
+	require.EqualError(t, err, `unsupported line terminator "\n\n"`)
+	_, err = Marshal(&people, WithSeparator("|"), WithTableStyle(StyleASCII))
+	require.EqualError(t, err, "separator can only be used with the plain table style")
+	_, err = Marshal(&people, WithSeparator("|"), WithLayout(&Layout{Columns: []Column{{Name: "Name", Width: 4}}}))
+	require.EqualError(t, err, "WithSeparator can't be used with WithLayout, the layout sets the column positions")
+}
+
+func TestMarshal_IgnoredFields(t *testing.T) {
+	type Person struct {
+		Name     string
+		Password string `column:"-"`
+		Session  string `json:"-"`
+		Cache    []byte `fw:"-"`
+		age      int
+		Nick     string    `json:"nick,omitempty"`
+		Score    int       `fw:",omitempty"`
+		Joined   time.Time `fw:",omitempty" format:"2006-01-02"`
+		Active   bool      `json:",omitempty"`
+	}
+	people := []Person{
+		{Name: "John", Password: "secret", Session: "s1", Cache: []byte{1}, age: 20, Nick: "jo", Score: 7,
+			Joined: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Active: true},
+		{Name: "Jane", age: 30},
+	}
+
+	b, err := Marshal(&people)
+	require.NoError(t, err)
+	assert.Equal(t, "Name nick Score Joined     Active\n"+
+		"John jo   7     2024-01-02 true  \n"+
+		"Jane                             ", string(b))
+
+	var obtained []Person
+	require.NoError(t, Unmarshal(b, &obtained))
+	people[0].Password, people[0].Session, people[0].Cache, people[0].age, people[1].age = "", "", nil, 0, 0
+	assert.Equal(t, people, obtained)
+
+	layout, err := LayoutOf(struct {
+		Name   string `fw:",width=4"`
+		hidden string
+		Secret string `column:"-"`
+	}{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Name"}, layout.Names())
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestMarshalWriter_WriteError(t *testing.T) {
+	type Person struct {
+		Name string
+	}
+	people := []Person{{Name: "John"}}
+
+	writeErr := errors.New("disk full")
+	assert.ErrorIs(t, MarshalWriter(failingWriter{err: writeErr}, &people), writeErr)
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	var items []TestStruct
+	data, err := os.ReadFile("./testdata/correct_all_supported.txt")
+	require.NoError(b, err)
+	require.NoError(b, Unmarshal(data, &items))
+
+	for _, rows := range []int{1, 1000} {
+		items := slices.Repeat(items[:1], rows)
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := Marshal(&items); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package fwencoder
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidHeader is returned when the header line doesn't match the struct columns in strict mode
+// or a column tagged with the required option is missing.
+var ErrInvalidHeader = errors.New("invalid header")
+
+func (t *structDecodeTarget) validateColumns() error {
+	found := make(map[string]bool, len(t.columns))
+	for _, c := range t.columns {
+		found[c.name] = true
+		if _, ok := t.plan.index[c.name]; !ok && t.strictHeaders && t.plan.extra == nil {
+			return fmt.Errorf("%w: unknown column %s", ErrInvalidHeader, c.name)
+		}
+	}
+	for _, f := range t.plan.fields {
+		required := t.strictHeaders || f.tag != nil && f.tag.required
+		if required && !found[f.name] {
+			return fmt.Errorf("%w: missing column %s", ErrInvalidHeader, f.name)
+		}
+	}
+	return nil
+}
+
+func (t *structDecodeTarget) validateHeader(headerLine string) error {
+	if err := t.validateColumns(); err != nil || !t.strictHeaders {
+		return err
+	}
+
+	// fields sharing a name share a column, extra columns aren't checked
+	columns := slices.DeleteFunc(slices.Clone(t.columns), func(c fwColumn) bool {
+		_, ok := t.plan.index[c.name]
+		return !ok
+	})
+	columns = slices.CompactFunc(columns, func(a, b fwColumn) bool { return a.name == b.name })
+	patterns := t.headerPatterns()
+	for _, c := range columns {
+		if matches := countWordMatches(headerLine, patterns[t.plan.index[c.name]].FindAllStringIndex(headerLine, -1)); matches > 1 {
+			return fmt.Errorf("%w: duplicate column %s", ErrInvalidHeader, c.name)
+		}
+	}
+	slices.SortStableFunc(columns, func(a, b fwColumn) int { return a.start - b.start })
+	for i := 1; i < len(columns); i++ {
+		if columns[i-1].end > columns[i].start {
+			return fmt.Errorf("%w: columns %s and %s overlap", ErrInvalidHeader, columns[i-1].name, columns[i].name)
+		}
+	}
+	if unknown := uncoveredColumns(headerLine, columns); len(unknown) > 0 && t.plan.extra == nil {
+		return fmt.Errorf("%w: unknown column %s", ErrInvalidHeader, unknown[0].name)
+	}
+	return nil
+}
+
+// countWordMatches counts the matches starting a word, "Name" in "First Name" but not in "FirstName".
+func countWordMatches(headerLine string, locs [][]int) int {
+	n := 0
+	for _, loc := range locs {
+		if loc[0] == 0 || headerLine[loc[0]-1] == ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+// uncoveredColumns splits the parts of the header line outside of the columns into columns like discoverHeaders,
+// every word starts a new column.
+func uncoveredColumns(headerLine string, columns []fwColumn) []fwColumn {
+	covered := make([]bool, utf8.RuneCountInString(headerLine))
+	for _, c := range columns {
+		for i := c.start; i < c.end; i++ {
+			covered[i] = true
+		}
+	}
+
+	lineRunes := []rune(headerLine)
+	var uncovered []fwColumn
+	for i := 0; i < len(lineRunes); i++ {
+		if covered[i] || unicode.IsSpace(lineRunes[i]) {
+			continue
+		}
+		// the column spans the word and the following spaces up to the next word or column
+		nameEnd := i
+		for nameEnd < len(lineRunes) && !covered[nameEnd] && !unicode.IsSpace(lineRunes[nameEnd]) {
+			nameEnd++
+		}
+		end := nameEnd
+		for end < len(lineRunes) && !covered[end] && unicode.IsSpace(lineRunes[end]) {
+			end++
+		}
+		uncovered = append(uncovered, fwColumn{name: string(lineRunes[i:nameEnd]), start: i, end: end})
+		i = end - 1
+	}
+	return uncovered
+}
+
+func (t *dynamicDecodeTarget) validateColumns() error {
+	// the layout columns are unique
+	return nil
+}
+
+func (t *dynamicDecodeTarget) validateHeader(string) error {
+	if !t.strictHeaders {
+		return nil
+	}
+	seen := make(map[string]bool, len(t.header.columns))
+	for _, name := range t.header.columns {
+		if seen[name] {
+			return fmt.Errorf("%w: duplicate column %s", ErrInvalidHeader, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
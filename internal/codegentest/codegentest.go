@@ -0,0 +1,72 @@
+// Package codegentest holds types with generated FixedWidthMarshaler and FixedWidthUnmarshaler methods,
+// the tests compare them with the reflective encoder and decoder.
+package codegentest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+//go:generate go run ../../cmd/fwgen -methods -type Payment -o payment_fw.go
+
+// Currency is a named string type.
+type Currency string
+
+// Cents is a named integer type.
+type Cents int64
+
+// Money implements driver.Valuer and sql.Scanner with a decimal text representation.
+type Money int64
+
+// Value implements driver.Valuer.
+func (m Money) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d.%02d", m/100, m%100), nil
+}
+
+// Scan implements sql.Scanner.
+func (m *Money) Scan(src any) error {
+	var units, cents int64
+	if _, err := fmt.Sscanf(fmt.Sprint(src), "%d.%d", &units, &cents); err != nil {
+		return err
+	}
+	*m = Money(units*100 + cents)
+	return nil
+}
+
+// Account is encoded as JSON.
+type Account struct {
+	Bank   string `json:"bank"`
+	Number string `json:"number"`
+}
+
+// Payment covers all kinds of fields supported by the generator.
+type Payment struct {
+	ID        uint32
+	Payer     string   `column:"Payer Name"`
+	Currency  Currency `fw:"Cur"`
+	Amount    Cents    `json:"amount"`
+	Rate      float32
+	Fee       float64 `fw:"prec=2"`
+	Priority  int8    `fw:",nullzeros"`
+	Confirmed bool
+	Date      time.Time `format:"2006-01-02"`
+	Settled   *time.Time
+	Reference *string `fw:",null=\\N|NULL"`
+	Retries   *int    `fw:",null=NULL"`
+	Limit     *int    `fw:",omitempty,null=NULL"`
+	Tags      []string
+	Meta      map[string]int
+	Account   Account
+	Backup    *Account
+	Discount  Cents             `json:"discount,omitempty"`
+	Due       time.Time         `fw:",omitempty" format:"2006-01-02"`
+	Labels    []string          `fw:",omitempty"`
+	Memo      string            `column:"-"`
+	Extra     map[string]string `fw:",extra"`
+	Note      sql.NullString
+	Paid      sql.NullTime     `format:"2006-01-02"`
+	Bonus     *sql.Null[int32] `fw:",null=NULL"`
+	Total     Money
+}
@@ -0,0 +1,368 @@
+// Code generated by fwgen. DO NOT EDIT.
+
+package codegentest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// MarshalFixedWidth implements fwencoder.FixedWidthMarshaler.
+func (v *Payment) MarshalFixedWidth(buf []byte, ends []int) ([]byte, []int, error) {
+	var (
+		b   []byte
+		err error
+	)
+	buf = strconv.AppendUint(buf, uint64(v.ID), 10)
+	ends = append(ends, len(buf))
+	buf = append(buf, v.Payer...)
+	ends = append(ends, len(buf))
+	buf = append(buf, string(v.Currency)...)
+	ends = append(ends, len(buf))
+	buf = strconv.AppendInt(buf, int64(v.Amount), 10)
+	ends = append(ends, len(buf))
+	buf = strconv.AppendFloat(buf, float64(v.Rate), 'g', -1, 32)
+	ends = append(ends, len(buf))
+	buf = strconv.AppendFloat(buf, v.Fee, 'f', 2, 64)
+	ends = append(ends, len(buf))
+	buf = strconv.AppendInt(buf, int64(v.Priority), 10)
+	ends = append(ends, len(buf))
+	buf = strconv.AppendBool(buf, v.Confirmed)
+	ends = append(ends, len(buf))
+	buf = v.Date.AppendFormat(buf, "2006-01-02")
+	ends = append(ends, len(buf))
+	if v.Settled == nil {
+	} else {
+		buf = (*v.Settled).AppendFormat(buf, time.RFC3339)
+	}
+	ends = append(ends, len(buf))
+	if v.Reference == nil {
+		buf = append(buf, "\\N"...)
+	} else {
+		buf = append(buf, *v.Reference...)
+	}
+	ends = append(ends, len(buf))
+	if v.Retries == nil {
+		buf = append(buf, "NULL"...)
+	} else {
+		buf = strconv.AppendInt(buf, int64((*v.Retries)), 10)
+	}
+	ends = append(ends, len(buf))
+	if !(v.Limit == nil) {
+		buf = strconv.AppendInt(buf, int64((*v.Limit)), 10)
+	}
+	ends = append(ends, len(buf))
+	if b, err = json.Marshal(v.Tags); err != nil {
+		return nil, nil, err
+	}
+	buf = append(buf, b...)
+	ends = append(ends, len(buf))
+	if b, err = json.Marshal(v.Meta); err != nil {
+		return nil, nil, err
+	}
+	buf = append(buf, b...)
+	ends = append(ends, len(buf))
+	if b, err = json.Marshal(v.Account); err != nil {
+		return nil, nil, err
+	}
+	buf = append(buf, b...)
+	ends = append(ends, len(buf))
+	if v.Backup == nil {
+	} else {
+		if b, err = json.Marshal(*v.Backup); err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, b...)
+	}
+	ends = append(ends, len(buf))
+	if !(v.Discount == 0) {
+		buf = strconv.AppendInt(buf, int64(v.Discount), 10)
+	}
+	ends = append(ends, len(buf))
+	if !(v.Due == (time.Time{})) {
+		buf = v.Due.AppendFormat(buf, "2006-01-02")
+	}
+	ends = append(ends, len(buf))
+	if !(v.Labels == nil) {
+		if b, err = json.Marshal(v.Labels); err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, b...)
+	}
+	ends = append(ends, len(buf))
+	ends = append(ends, len(buf))
+	ends = append(ends, len(buf))
+	if !v.Note.Valid {
+	} else {
+		buf = append(buf, v.Note.String...)
+	}
+	ends = append(ends, len(buf))
+	if !v.Paid.Valid {
+	} else {
+		buf = v.Paid.Time.AppendFormat(buf, "2006-01-02")
+	}
+	ends = append(ends, len(buf))
+	if v.Bonus == nil {
+		buf = append(buf, "NULL"...)
+	} else {
+		if !(*v.Bonus).Valid {
+			buf = append(buf, "NULL"...)
+		} else {
+			buf = strconv.AppendInt(buf, int64((*v.Bonus).V), 10)
+		}
+	}
+	ends = append(ends, len(buf))
+	{
+		x, err := v.Total.Value()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch x := x.(type) {
+		case nil:
+		case []byte:
+			buf = append(buf, x...)
+		case string:
+			buf = append(buf, x...)
+		case float64:
+			buf = strconv.AppendFloat(buf, x, 'g', -1, 64)
+		case time.Time:
+			buf = x.AppendFormat(buf, time.RFC3339)
+		default:
+			buf = fmt.Append(buf, x)
+		}
+	}
+	ends = append(ends, len(buf))
+	return buf, ends, nil
+}
+
+// UnmarshalFixedWidth implements fwencoder.FixedWidthUnmarshaler.
+func (v *Payment) UnmarshalFixedWidth(cells [][]byte, columns []int) error {
+	if i := columns[0]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x, err := strconv.ParseUint(string(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf(`filed casting "%s" to "ID:%T": %w`, raw, v.ID, err)
+		}
+		if x > math.MaxUint32 {
+			return fmt.Errorf(`value %v is too big for field ID:%T`, x, v.ID)
+		}
+		v.ID = uint32(x)
+	}
+	if i := columns[1]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x := string(raw)
+		v.Payer = x
+	}
+	if i := columns[2]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x := string(raw)
+		v.Currency = Currency(x)
+	}
+	if i := columns[3]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x, err := strconv.ParseInt(string(raw), 10, 0)
+		if err != nil {
+			return fmt.Errorf(`filed casting "%s" to "Amount:%T": %w`, raw, v.Amount, err)
+		}
+		v.Amount = Cents(x)
+	}
+	if i := columns[4]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return fmt.Errorf(`filed casting "%s" to "Rate:%T": %w`, raw, v.Rate, err)
+		}
+		if a := math.Abs(x); a > math.MaxFloat32 && a <= math.MaxFloat64 {
+			return fmt.Errorf(`value %v is too big for field Rate:%T`, x, v.Rate)
+		}
+		v.Rate = float32(x)
+	}
+	if i := columns[5]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return fmt.Errorf(`filed casting "%s" to "Fee:%T": %w`, raw, v.Fee, err)
+		}
+		v.Fee = x
+	}
+	if i := columns[6]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) == 0 || len(bytes.Trim(raw, "0")) != 0 {
+			x, err := strconv.ParseInt(string(raw), 10, 0)
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Priority:%T": %w`, raw, v.Priority, err)
+			}
+			if x < math.MinInt8 || x > math.MaxInt8 {
+				return fmt.Errorf(`value %v is too big for field Priority:%T`, x, v.Priority)
+			}
+			v.Priority = int8(x)
+		}
+	}
+	if i := columns[7]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x, err := strconv.ParseBool(string(raw))
+		if err != nil {
+			return fmt.Errorf(`filed casting "%s" to "Confirmed:%T": %w`, raw, v.Confirmed, err)
+		}
+		v.Confirmed = x
+	}
+	if i := columns[8]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		x, err := time.Parse("2006-01-02", string(raw))
+		if err != nil {
+			return fmt.Errorf(`filed casting "%s" to "Date:%T": %w`, raw, v.Date, err)
+		}
+		v.Date = x
+	}
+	if i := columns[9]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			x, err := time.Parse(time.RFC3339, string(raw))
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Settled:%T": %w`, raw, v.Settled, err)
+			}
+			v.Settled = &x
+		}
+	}
+	if i := columns[10]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 && string(raw) != "\\N" && string(raw) != "NULL" {
+			x := string(raw)
+			v.Reference = &x
+		}
+	}
+	if i := columns[11]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 && string(raw) != "NULL" {
+			x, err := strconv.ParseInt(string(raw), 10, 0)
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Retries:%T": %w`, raw, v.Retries, err)
+			}
+			p := int(x)
+			v.Retries = &p
+		}
+	}
+	if i := columns[12]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 && string(raw) != "NULL" {
+			x, err := strconv.ParseInt(string(raw), 10, 0)
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Limit:%T": %w`, raw, v.Limit, err)
+			}
+			p := int(x)
+			v.Limit = &p
+		}
+	}
+	if i := columns[13]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		var x []string
+		if err := json.Unmarshal(raw, &x); err != nil {
+			return fmt.Errorf(`can't unmarshal '"%s" to %T: %w`, raw, v.Tags, err)
+		}
+		v.Tags = x
+	}
+	if i := columns[14]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		var x map[string]int
+		if err := json.Unmarshal(raw, &x); err != nil {
+			return fmt.Errorf(`can't unmarshal '"%s" to %T: %w`, raw, v.Meta, err)
+		}
+		v.Meta = x
+	}
+	if i := columns[15]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		var x Account
+		if err := json.Unmarshal(raw, &x); err != nil {
+			return fmt.Errorf(`can't unmarshal '"%s" to %T: %w`, raw, v.Account, err)
+		}
+		v.Account = x
+	}
+	if i := columns[16]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			var x *Account
+			if err := json.Unmarshal(raw, &x); err != nil {
+				return fmt.Errorf(`can't unmarshal '"%s" to %T: %w`, raw, v.Backup, err)
+			}
+			v.Backup = x
+		}
+	}
+	if i := columns[17]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			x, err := strconv.ParseInt(string(raw), 10, 0)
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Discount:%T": %w`, raw, v.Discount, err)
+			}
+			v.Discount = Cents(x)
+		}
+	}
+	if i := columns[18]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			x, err := time.Parse("2006-01-02", string(raw))
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Due:%T": %w`, raw, v.Due, err)
+			}
+			v.Due = x
+		}
+	}
+	if i := columns[19]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			var x []string
+			if err := json.Unmarshal(raw, &x); err != nil {
+				return fmt.Errorf(`can't unmarshal '"%s" to %T: %w`, raw, v.Labels, err)
+			}
+			v.Labels = x
+		}
+	}
+	if i := columns[22]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			x := string(raw)
+			v.Note.String = x
+			v.Note.Valid = true
+		}
+	}
+	if i := columns[23]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			x, err := time.Parse("2006-01-02", string(raw))
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Paid:%T": %w`, raw, v.Paid.Time, err)
+			}
+			v.Paid.Time = x
+			v.Paid.Valid = true
+		}
+	}
+	if i := columns[24]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 && string(raw) != "NULL" {
+			v.Bonus = new(sql.Null[int32])
+			x, err := strconv.ParseInt(string(raw), 10, 0)
+			if err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Bonus:%T": %w`, raw, v.Bonus.V, err)
+			}
+			if x < math.MinInt32 || x > math.MaxInt32 {
+				return fmt.Errorf(`value %v is too big for field Bonus:%T`, x, v.Bonus.V)
+			}
+			v.Bonus.V = int32(x)
+			v.Bonus.Valid = true
+		}
+	}
+	if i := columns[25]; i >= 0 {
+		raw := bytes.TrimSpace(cells[i])
+		if len(raw) != 0 {
+			if err := v.Total.Scan(string(raw)); err != nil {
+				return fmt.Errorf(`filed casting "%s" to "Total:%T": %w`, raw, v.Total, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package structsrc builds reflect types mirroring struct declarations found in Go source files.
+// It lets command line tools process tagged structs the same way the library does without compiling them.
+package structsrc
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTypeNotFound is returned when the package has no struct type with the requested name
+	ErrTypeNotFound = errors.New("struct type not found")
+	// ErrUnsupportedType is returned when a field type can't be resolved or mirrored by a reflect type
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+var basicTypes = map[types.BasicKind]reflect.Type{
+	types.Bool:    reflect.TypeOf(false),
+	types.Int:     reflect.TypeOf(0),
+	types.Int8:    reflect.TypeOf(int8(0)),
+	types.Int16:   reflect.TypeOf(int16(0)),
+	types.Int32:   reflect.TypeOf(int32(0)),
+	types.Int64:   reflect.TypeOf(int64(0)),
+	types.Uint:    reflect.TypeOf(uint(0)),
+	types.Uint8:   reflect.TypeOf(uint8(0)),
+	types.Uint16:  reflect.TypeOf(uint16(0)),
+	types.Uint32:  reflect.TypeOf(uint32(0)),
+	types.Uint64:  reflect.TypeOf(uint64(0)),
+	types.Float32: reflect.TypeOf(float32(0)),
+	types.Float64: reflect.TypeOf(float64(0)),
+	types.String:  reflect.TypeOf(""),
+}
+
+// knownTypes are the named types the library handles differently from their underlying types.
+var knownTypes = map[string]reflect.Type{
+	"time.Time":                    reflect.TypeOf(time.Time{}),
+	"database/sql.NullString":      reflect.TypeOf(sql.NullString{}),
+	"database/sql.NullInt64":       reflect.TypeOf(sql.NullInt64{}),
+	"database/sql.NullInt32":       reflect.TypeOf(sql.NullInt32{}),
+	"database/sql.NullInt16":       reflect.TypeOf(sql.NullInt16{}),
+	"database/sql.NullByte":        reflect.TypeOf(sql.NullByte{}),
+	"database/sql.NullFloat64":     reflect.TypeOf(sql.NullFloat64{}),
+	"database/sql.NullBool":        reflect.TypeOf(sql.NullBool{}),
+	"database/sql.NullTime":        reflect.TypeOf(sql.NullTime{}),
+	"database/sql.Null[string]":    reflect.TypeOf(sql.Null[string]{}),
+	"database/sql.Null[bool]":      reflect.TypeOf(sql.Null[bool]{}),
+	"database/sql.Null[int]":       reflect.TypeOf(sql.Null[int]{}),
+	"database/sql.Null[int8]":      reflect.TypeOf(sql.Null[int8]{}),
+	"database/sql.Null[int16]":     reflect.TypeOf(sql.Null[int16]{}),
+	"database/sql.Null[int32]":     reflect.TypeOf(sql.Null[int32]{}),
+	"database/sql.Null[int64]":     reflect.TypeOf(sql.Null[int64]{}),
+	"database/sql.Null[uint]":      reflect.TypeOf(sql.Null[uint]{}),
+	"database/sql.Null[uint8]":     reflect.TypeOf(sql.Null[uint8]{}),
+	"database/sql.Null[uint16]":    reflect.TypeOf(sql.Null[uint16]{}),
+	"database/sql.Null[uint32]":    reflect.TypeOf(sql.Null[uint32]{}),
+	"database/sql.Null[uint64]":    reflect.TypeOf(sql.Null[uint64]{}),
+	"database/sql.Null[float32]":   reflect.TypeOf(sql.Null[float32]{}),
+	"database/sql.Null[float64]":   reflect.TypeOf(sql.Null[float64]{}),
+	"database/sql.Null[time.Time]": reflect.TypeOf(sql.Null[time.Time]{}),
+}
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+// LoadPackage type checks the non-test Go files of the directory. Type errors are ignored, so a stale
+// generated file doesn't prevent its regeneration, types which can't be resolved are invalid.
+func LoadPackage(dir string) (*types.Package, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var astFiles []*ast.File
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		astFiles = append(astFiles, f)
+	}
+	if len(astFiles) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	pkg, err := conf.Check(absDir, fset, astFiles, nil)
+	if pkg == nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Load type checks the package in the directory and returns a reflect type mirroring the exported fields
+// of the named struct type, including their tags. Time and database/sql null types are kept, other named
+// types are resolved to their underlying types. Types which can't be resolved are an error.
+func Load(dir, typeName string) (reflect.Type, error) {
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotFound, typeName)
+	}
+	if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+		return nil, fmt.Errorf("%w: %s is not a struct", ErrTypeNotFound, typeName)
+	}
+	r := &resolver{resolving: make(map[*types.Named]bool)}
+	return r.resolve(obj.Type())
+}
+
+type resolver struct {
+	resolving map[*types.Named]bool
+}
+
+func (r *resolver) resolve(t types.Type) (reflect.Type, error) {
+	switch t := types.Unalias(t).(type) {
+	case *types.Basic:
+		if rt, ok := basicTypes[t.Kind()]; ok {
+			return rt, nil
+		}
+	case *types.Named:
+		return r.resolveNamed(t)
+	case *types.Pointer:
+		elem, err := r.resolve(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PointerTo(elem), nil
+	case *types.Slice:
+		elem, err := r.resolve(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	case *types.Array:
+		elem, err := r.resolve(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ArrayOf(int(t.Len()), elem), nil
+	case *types.Map:
+		return r.resolveMap(t)
+	case *types.Struct:
+		return r.resolveStruct(t)
+	case *types.Interface:
+		return anyType, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+}
+
+func (r *resolver) resolveNamed(t *types.Named) (reflect.Type, error) {
+	if rt, ok := knownTypes[types.TypeString(t, nil)]; ok {
+		return rt, nil
+	}
+	if r.resolving[t] {
+		return nil, fmt.Errorf("%w: %s is recursive", ErrUnsupportedType, t)
+	}
+	r.resolving[t] = true
+	defer delete(r.resolving, t)
+	return r.resolve(t.Underlying())
+}
+
+func (r *resolver) resolveMap(t *types.Map) (reflect.Type, error) {
+	key, err := r.resolve(t.Key())
+	if err != nil {
+		return nil, err
+	}
+	value, err := r.resolve(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	if !key.Comparable() {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	}
+	return reflect.MapOf(key, value), nil
+}
+
+func (r *resolver) resolveStruct(s *types.Struct) (reflect.Type, error) {
+	var fields []reflect.StructField
+	for i := range s.NumFields() {
+		field := s.Field(i)
+		if !field.Exported() || field.Embedded() {
+			continue
+		}
+		t, err := r.resolve(field.Type())
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name(), err)
+		}
+		fields = append(fields, reflect.StructField{Name: field.Name(), Type: t, Tag: reflect.StructTag(s.Tag(i))})
+	}
+	return reflect.StructOf(fields), nil
+}
@@ -0,0 +1,84 @@
+package structsrc
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `package sample
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Amount float64
+
+type Address struct {
+	City string
+}
+
+type Person struct {
+	Name     string    ` + "`" + `fw:",width=16" description:"Full name"` + "`" + `
+	Balance  Amount
+	Birthday *time.Time
+	Tags     []string
+	Extra    map[string]string
+	Paid     sql.NullTime
+	Timeout  time.Duration
+	Home     Address
+	A, B     int
+	hidden   string
+}
+
+type NotStruct int
+
+type Unresolved struct {
+	Other other.Type
+}
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sample.go"), []byte(source), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sample_test.go"), []byte("package sample\n\ntype Person int"), 0o600))
+
+	typ, err := Load(dir, "Person")
+	require.NoError(t, err)
+
+	expected := []reflect.StructField{
+		{Name: "Name", Type: reflect.TypeOf(""), Tag: `fw:",width=16" description:"Full name"`},
+		{Name: "Balance", Type: reflect.TypeOf(float64(0))},
+		{Name: "Birthday", Type: reflect.TypeOf(&time.Time{})},
+		{Name: "Tags", Type: reflect.TypeOf([]string{})},
+		{Name: "Extra", Type: reflect.TypeOf(map[string]string{})},
+		{Name: "Paid", Type: reflect.TypeOf(sql.NullTime{})},
+		{Name: "Timeout", Type: reflect.TypeOf(int64(0))},
+		{Name: "Home", Type: reflect.TypeOf(struct{ City string }{})},
+		{Name: "A", Type: reflect.TypeOf(0)},
+		{Name: "B", Type: reflect.TypeOf(0)},
+	}
+	require.Equal(t, len(expected), typ.NumField())
+	for i, f := range expected {
+		assert.Equal(t, f.Name, typ.Field(i).Name)
+		assert.Equal(t, f.Type, typ.Field(i).Type, f.Name)
+		assert.Equal(t, f.Tag, typ.Field(i).Tag, f.Name)
+	}
+
+	_, err = Load(dir, "Unknown")
+	require.ErrorIs(t, err, ErrTypeNotFound)
+
+	_, err = Load(dir, "NotStruct")
+	require.ErrorIs(t, err, ErrTypeNotFound)
+
+	_, err = Load(dir, "Unresolved")
+	require.ErrorIs(t, err, ErrUnsupportedType)
+	require.EqualError(t, err, "field Other: unsupported type: invalid type")
+}
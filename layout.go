@@ -0,0 +1,489 @@
+package fwencoder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const descriptionTagName = "description"
+
+var (
+	// ErrIncorrectLayout is returned when a layout has invalid columns
+	ErrIncorrectLayout = errors.New("incorrect layout")
+	// ErrLayoutRequired is returned when data without the header line is processed without a layout
+	ErrLayoutRequired = errors.New("layout is required for data without header")
+	// ErrValueTooLong is returned by the encoder when a value is wider than its column with the OverflowError policy
+	ErrValueTooLong = errors.New("value is too long")
+)
+
+// Alignment defines how a value is aligned within its column.
+type Alignment int
+
+const (
+	// AlignLeft pads values on the right. It is the default alignment.
+	AlignLeft Alignment = iota
+	// AlignRight pads values on the left.
+	AlignRight
+)
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Alignment) MarshalText() ([]byte, error) {
+	switch a {
+	case AlignLeft:
+		return []byte("left"), nil
+	case AlignRight:
+		return []byte("right"), nil
+	}
+	return nil, fmt.Errorf("unknown alignment %d", a)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *Alignment) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "", "left":
+		*a = AlignLeft
+	case "right":
+		*a = AlignRight
+	default:
+		return fmt.Errorf("unknown alignment %q", text)
+	}
+	return nil
+}
+
+// OverflowPolicy defines how a value wider than its column is written.
+type OverflowPolicy int
+
+const (
+	// OverflowError fails the encoding with ErrValueTooLong. It is the default policy.
+	OverflowError OverflowPolicy = iota
+	// OverflowTruncate keeps the beginning of the value and cuts the characters on the right.
+	OverflowTruncate
+	// OverflowTruncateLeft keeps the end of the value and cuts the characters on the left.
+	OverflowTruncateLeft
+	// OverflowEllipsis keeps the beginning of the value and replaces the last fitting character with an ellipsis.
+	OverflowEllipsis
+)
+
+// ellipsis ends the values cut with OverflowEllipsis.
+const ellipsis = "…"
+
+// MarshalText implements encoding.TextMarshaler.
+func (p OverflowPolicy) MarshalText() ([]byte, error) {
+	switch p {
+	case OverflowError:
+		return []byte("error"), nil
+	case OverflowTruncate:
+		return []byte("truncate"), nil
+	case OverflowTruncateLeft:
+		return []byte("truncate-left"), nil
+	case OverflowEllipsis:
+		return []byte("ellipsis"), nil
+	}
+	return nil, fmt.Errorf("unknown overflow policy %d", p)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *OverflowPolicy) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "", "error":
+		*p = OverflowError
+	case "truncate", "truncate-right":
+		*p = OverflowTruncate
+	case "truncate-left":
+		*p = OverflowTruncateLeft
+	case "ellipsis":
+		*p = OverflowEllipsis
+	default:
+		return fmt.Errorf("unknown overflow policy %q", text)
+	}
+	return nil
+}
+
+// ColumnType is a hint about the type of column values.
+type ColumnType string
+
+// Column types
+const (
+	TypeString ColumnType = "string"
+	TypeInt    ColumnType = "int"
+	TypeUint   ColumnType = "uint"
+	TypeFloat  ColumnType = "float"
+	TypeBool   ColumnType = "bool"
+	TypeTime   ColumnType = "time"
+	TypeJSON   ColumnType = "json"
+)
+
+// Column describes position and formatting of a single column.
+type Column struct {
+	// Name is the column name. It's matched against struct fields the same way as header names.
+	Name string `json:"name"`
+	// Start is the 0-based position of the first column character in the line
+	Start int `json:"start"`
+	// Width is the number of characters occupied by the column
+	Width int `json:"width"`
+	// Align defines the alignment of values, AlignLeft by default
+	Align Alignment `json:"align,omitempty"`
+	// Pad is the character used to pad values up to the column width, space by default
+	Pad string `json:"pad,omitempty"`
+	// Overflow defines how values wider than the column are written, OverflowError by default
+	Overflow OverflowPolicy `json:"overflow,omitempty"`
+	// Type is a hint about the type of values
+	Type ColumnType `json:"type,omitempty"`
+	// Format is the time layout of TypeTime values. Struct fields keep using their format tag.
+	Format string `json:"format,omitempty"`
+	// Description is a human readable description of the column used in the layout documentation
+	Description string `json:"description,omitempty"`
+}
+
+// End returns the position right after the last column character.
+func (c *Column) End() int {
+	return c.Start + c.Width
+}
+
+func (c *Column) padRune() rune {
+	if c.Pad == "" {
+		return ' '
+	}
+	r, _ := utf8.DecodeRuneInString(c.Pad)
+	return r
+}
+
+// Layout is an ordered list of columns with fixed positions. It can be built in code, derived from a struct type
+// with LayoutOf or loaded from JSON with LoadLayoutJSON, and passed to the encoder and decoder with WithLayout.
+type Layout struct {
+	Columns []Column `json:"columns"`
+}
+
+// Validate checks that all columns have names, positive widths and don't overlap.
+func (l *Layout) Validate() error {
+	if len(l.Columns) == 0 {
+		return fmt.Errorf("%w: no columns", ErrIncorrectLayout)
+	}
+	names := make(map[string]bool, len(l.Columns))
+	for i := range l.Columns {
+		c := &l.Columns[i]
+		switch {
+		case c.Name == "":
+			return fmt.Errorf("%w: column %d has no name", ErrIncorrectLayout, i)
+		case names[c.Name]:
+			return fmt.Errorf("%w: duplicate column %s", ErrIncorrectLayout, c.Name)
+		case c.Start < 0:
+			return fmt.Errorf("%w: column %s has negative start %d", ErrIncorrectLayout, c.Name, c.Start)
+		case c.Width <= 0:
+			return fmt.Errorf("%w: column %s has non-positive width %d", ErrIncorrectLayout, c.Name, c.Width)
+		case utf8.RuneCountInString(c.Pad) > 1:
+			return fmt.Errorf("%w: column %s pad must be a single character", ErrIncorrectLayout, c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	sorted := l.sortedColumns()
+	for i := 1; i < len(sorted); i++ {
+		if prev, c := sorted[i-1], sorted[i]; c.Start < prev.End() {
+			return fmt.Errorf("%w: columns %s and %s overlap", ErrIncorrectLayout, prev.Name, c.Name)
+		}
+	}
+	return nil
+}
+
+// LineLength returns the length of a line described by the layout.
+func (l *Layout) LineLength() int {
+	length := 0
+	for i := range l.Columns {
+		length = max(length, l.Columns[i].End())
+	}
+	return length
+}
+
+// Names returns the column names in layout order.
+func (l *Layout) Names() []string {
+	names := make([]string, len(l.Columns))
+	for i := range l.Columns {
+		names[i] = l.Columns[i].Name
+	}
+	return names
+}
+
+// Column returns the column with the given name.
+func (l *Layout) Column(name string) (*Column, bool) {
+	for i := range l.Columns {
+		if l.Columns[i].Name == name {
+			return &l.Columns[i], true
+		}
+	}
+	return nil, false
+}
+
+func (l *Layout) sortedColumns() []*Column {
+	sorted := make([]*Column, len(l.Columns))
+	for i := range l.Columns {
+		sorted[i] = &l.Columns[i]
+	}
+	slices.SortStableFunc(sorted, func(a, b *Column) int {
+		return a.Start - b.Start
+	})
+	return sorted
+}
+
+func (l *Layout) fwColumns() []fwColumn {
+	columns := make([]fwColumn, len(l.Columns))
+	for i := range l.Columns {
+		c := &l.Columns[i]
+		columns[i] = fwColumn{name: c.Name, start: c.Start, end: c.End(), align: c.Align, pad: c.padRune()}
+	}
+	return columns
+}
+
+// LoadLayoutJSON reads a layout in JSON format and validates it, e.g.
+//
+//	{"columns": [
+//	    {"name": "Name", "start": 0, "width": 16},
+//	    {"name": "Amount", "start": 16, "width": 10, "align": "right", "pad": "0", "type": "float"}
+//	]}
+func LoadLayoutJSON(reader io.Reader) (*Layout, error) {
+	var layout Layout
+	if err := json.NewDecoder(reader).Decode(&layout); err != nil {
+		return nil, err
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// LayoutOf derives a layout from a struct, a pointer to a struct or a slice of them.
+// Column names are resolved the same way as for the header line. Positions and padding are read from the `fw` tag:
+//
+//	type Person struct {
+//	    Name     string    `fw:",width=16"`
+//	    Postcode int       `fw:",width=8,align=right,pad=0"`
+//	    Birthday time.Time `fw:",start=30,width=8" format:"20060102"`
+//	}
+//
+// Column descriptions are read from the `description` tag. Every field must have a width.
+// Columns without a start follow the previous column.
+func LayoutOf(v any) (*Layout, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrIncorrectInputValue
+	}
+
+	layout := &Layout{Columns: make([]Column, 0, t.NumField())}
+	start := 0
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if isIgnoredField(&field) {
+			continue
+		}
+		tag, err := parseFwTag(&field)
+		if err != nil {
+			return nil, err
+		}
+		if tag.extra {
+			// the extra columns aren't known in advance
+			continue
+		}
+		if tag.width <= 0 {
+			return nil, fmt.Errorf("%w: field %s has no width", ErrIncorrectLayout, field.Name)
+		}
+		if tag.hasStart {
+			start = tag.start
+		}
+		column := Column{
+			Name:        getRefName(&field),
+			Start:       start,
+			Width:       tag.width,
+			Align:       tag.align,
+			Overflow:    tag.overflow,
+			Type:        columnTypeOf(field.Type),
+			Description: field.Tag.Get(descriptionTagName),
+		}
+		if tag.pad != ' ' {
+			column.Pad = string(tag.pad)
+		}
+		if column.Type == TypeTime {
+			column.Format = getTimeFormat(&field)
+		}
+		layout.Columns = append(layout.Columns, column)
+		start = column.End()
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+func columnTypeOf(t reflect.Type) ColumnType {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isSQLNullType(t) {
+		t = t.Field(0).Type
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return TypeInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeUint
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return TypeTime
+		}
+	}
+	return TypeJSON
+}
+
+// writeLayoutData writes the header line unless disabled and the data lines with values at the layout positions.
+func writeLayoutData(lines *lineWriter, source encodeSource, options *encoderOptions) error {
+	layout := options.layout
+	if options.header {
+		line, err := renderLayoutLine(layout, func(i int) (string, *fieldPlan, error) {
+			return truncateRunes(layout.Columns[i].Name, layout.Columns[i].Width), nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := lines.writeLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	rowsCount := source.recordCount()
+	for row := range rowsCount {
+		line, err := renderLayoutLine(layout, func(i int) (string, *fieldPlan, error) {
+			value, field, err := source.cell(row, i)
+			if err != nil {
+				return "", nil, err
+			}
+			if field == nil && layout.Columns[i].Format != "" {
+				field = &fieldPlan{StructField: reflect.StructField{Tag: reflect.StructTag(format + ":" + strconv.Quote(layout.Columns[i].Format))}}
+			}
+			text, err := renderValue(value, field)
+			return text, field, err
+		})
+		if err != nil {
+			return fmt.Errorf("item %d: %w", row, err)
+		}
+		if err := lines.writeLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderLayoutLine(layout *Layout, cell func(columnIndex int) (string, *fieldPlan, error)) (string, error) {
+	line := make([]rune, layout.LineLength())
+	for i := range line {
+		line[i] = ' '
+	}
+	for i := range layout.Columns {
+		c := &layout.Columns[i]
+		value, field, err := cell(i)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		if utf8.RuneCountInString(value) > c.Width {
+			if c.Overflow == OverflowError {
+				return "", newColumnOverflowError(c.Name, field, []byte(value), c.Width)
+			}
+			value = string(fitValue([]byte(value), 0, c.Width, c.Overflow))
+		}
+		pad := cellPad([]byte(value), c.padRune(), nullToken(field))
+		copy(line[c.Start:], []rune(padValue(value, c.Width, c.Align, pad)))
+	}
+	return string(line), nil
+}
+
+// fitValue cuts buf[start:] to width characters by the overflow policy other than OverflowError.
+// The value is cut in place, the returned buffer ends with the cut value.
+func fitValue(buf []byte, start, width int, policy OverflowPolicy) []byte {
+	value := buf[start:]
+	switch policy {
+	case OverflowTruncateLeft:
+		return append(buf[:start], value[runeOffset(value, utf8.RuneCount(value)-width):]...)
+	case OverflowEllipsis:
+		return append(buf[:start+runeOffset(value, width-1)], ellipsis...)
+	default:
+		return buf[:start+runeOffset(value, width)]
+	}
+}
+
+// runeOffset returns the byte offset of the n-th character of b.
+func runeOffset(b []byte, n int) int {
+	offset := 0
+	for range n {
+		_, size := utf8.DecodeRune(b[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// newColumnOverflowError reports a value wider than its column, the struct field is named if it differs from the column.
+func newColumnOverflowError(column string, field *fieldPlan, value []byte, width int) error {
+	if field != nil && field.Name != "" && field.Name != column {
+		column += " (field " + field.Name + ")"
+	}
+	return fmt.Errorf("column %s: %w: %q doesn't fit in %d characters", column, ErrValueTooLong, value, width)
+}
+
+// truncateRunes returns the first n runes of s.
+func truncateRunes(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
+
+// stripPadding removes the column padding from a raw cell. Zero padding of right aligned numbers
+// is removed after the sign, a cell consisting of zeros only is reduced to a single zero.
+func stripPadding(rawValue string, align Alignment, pad rune) string {
+	if pad == 0 || pad == ' ' {
+		return rawValue
+	}
+	if align == AlignLeft {
+		// cells of tables with the header line end with the column separator
+		return strings.TrimRight(strings.TrimRight(rawValue, " "), string(pad))
+	}
+
+	value := strings.TrimSpace(rawValue)
+	if value == "" {
+		// null cells are padded with spaces
+		return ""
+	}
+	sign := ""
+	if pad == '0' && value != "" && (value[0] == '-' || value[0] == '+') {
+		sign, value = value[:1], value[1:]
+	}
+	value = strings.TrimLeft(value, string(pad))
+	if pad == '0' && (value == "" || value[0] == '.') {
+		value = "0" + value
+	}
+	return sign + value
+}
+
+// padValue aligns the value within width characters using the pad character. Zero padding of right aligned values
+// is inserted after the sign.
+func padValue(value string, width int, align Alignment, pad rune) string {
+	return string(appendPadded(nil, []byte(value), width, align, pad))
+}
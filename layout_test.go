@@ -0,0 +1,233 @@
+package fwencoder
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type LayoutPerson struct {
+	Name     string    `fw:",width=8"`
+	Balance  int       `fw:",width=6,align=right,pad=0"`
+	Birthday time.Time `fw:"Bday,start=16,width=8" format:"20060102"`
+}
+
+func TestLayoutOf(t *testing.T) {
+	layout, err := LayoutOf(&[]LayoutPerson{})
+	require.NoError(t, err)
+	assert.Equal(t, &Layout{Columns: []Column{
+		{Name: "Name", Start: 0, Width: 8, Type: TypeString},
+		{Name: "Balance", Start: 8, Width: 6, Align: AlignRight, Pad: "0", Type: TypeInt},
+		{Name: "Bday", Start: 16, Width: 8, Type: TypeTime, Format: "20060102"},
+	}}, layout)
+	assert.Equal(t, 24, layout.LineLength())
+
+	type NoWidth struct {
+		Name string
+	}
+	_, err = LayoutOf(NoWidth{})
+	require.ErrorIs(t, err, ErrIncorrectLayout)
+
+	type BadTag struct {
+		Name string `fw:",width=x"`
+	}
+	_, err = LayoutOf(BadTag{})
+	require.EqualError(t, err, `field Name: invalid fw tag option "width=x": strconv.Atoi: parsing "x": invalid syntax`)
+
+	type NegativeWidth struct {
+		Name string `fw:",width=-3"`
+	}
+	_, err = LayoutOf(NegativeWidth{})
+	require.EqualError(t, err, `field Name: invalid fw tag option "width=-3": non-positive width`)
+	_, err = Marshal(&[]NegativeWidth{{}})
+	require.EqualError(t, err, `field Name: invalid fw tag option "width=-3": non-positive width`)
+
+	type NegativeStart struct {
+		Name string `fw:",start=-1,width=4"`
+	}
+	_, err = LayoutOf(NegativeStart{})
+	require.EqualError(t, err, `field Name: invalid fw tag option "start=-1": negative start`)
+	err = Unmarshal([]byte("Name\nJohn"), &[]NegativeStart{})
+	require.EqualError(t, err, `field Name: invalid fw tag option "start=-1": negative start`)
+
+	_, err = LayoutOf(1)
+	require.ErrorIs(t, err, ErrIncorrectInputValue)
+}
+
+func TestLayout_RoundTrip(t *testing.T) {
+	layout, err := LayoutOf(LayoutPerson{})
+	require.NoError(t, err)
+
+	people := []LayoutPerson{
+		{Name: "John", Balance: 125, Birthday: time.Date(1987, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "Jane", Balance: -42, Birthday: time.Date(1965, 12, 3, 0, 0, 0, 0, time.UTC)},
+		{Name: "Bob", Balance: 0, Birthday: time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	b, err := Marshal(&people, WithLayout(layout), WithoutHeader())
+	require.NoError(t, err)
+	assert.Equal(t, "John    000125  19870101\n"+
+		"Jane    -00042  19651203\n"+
+		"Bob     000000  20000229", string(b))
+
+	var obtained []LayoutPerson
+	require.NoError(t, Unmarshal(b, &obtained, WithLayout(layout), WithoutHeader()))
+	assert.Equal(t, people, obtained)
+
+	b, err = Marshal(&people, WithLayout(layout))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "Name    Balanc  Bday    \n"))
+
+	require.NoError(t, Unmarshal(b, &obtained, WithLayout(layout)))
+	assert.Equal(t, people, obtained)
+
+	var records []Record
+	require.NoError(t, Unmarshal(b, &records, WithLayout(layout)))
+	assert.Equal(t, []string{"Name", "Balance", "Bday"}, records[1].Columns())
+	assert.Equal(t, "-42", records[1].String("Balance"))
+
+	_, err = Marshal(&[]LayoutPerson{{Balance: 1234567}}, WithLayout(layout))
+	require.EqualError(t, err, `item 0: column Balance: value is too long: "1234567" doesn't fit in 6 characters`)
+}
+
+func TestLayout_Overflow(t *testing.T) {
+	layout := &Layout{Columns: []Column{
+		{Name: "Name", Width: 4, Overflow: OverflowEllipsis},
+		{Name: "Code", Start: 4, Width: 3, Overflow: OverflowTruncateLeft},
+	}}
+	rows := []map[string]any{{"Name": "Alexander", "Code": "12345"}}
+
+	b, err := Marshal(&rows, WithLayout(layout), WithoutHeader())
+	require.NoError(t, err)
+	assert.Equal(t, "Ale…345", string(b))
+
+	var decoded Layout
+	require.NoError(t, json.Unmarshal([]byte(`{"columns": [{"name": "A", "width": 1, "overflow": "truncate-right"}]}`), &decoded))
+	assert.Equal(t, OverflowTruncate, decoded.Columns[0].Overflow)
+}
+
+func TestLayout_Maps(t *testing.T) {
+	layout := &Layout{Columns: []Column{
+		{Name: "ID", Start: 0, Width: 4, Align: AlignRight},
+		{Name: "Date", Start: 5, Width: 10, Format: "2006-01-02"},
+	}}
+	rows := []map[string]any{{"ID": 7, "Date": time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}}
+	b, err := Marshal(&rows, WithLayout(layout), WithoutHeader())
+	require.NoError(t, err)
+	assert.Equal(t, "   7 2024-01-31", string(b))
+}
+
+func TestLayout_HeaderValidation(t *testing.T) {
+	type Address struct {
+		City string `fw:",required"`
+		Zip  string
+	}
+	layout := &Layout{Columns: []Column{{Name: "Zip", Start: 0, Width: 5}, {Name: "Country", Start: 5, Width: 2}}}
+
+	var obtained []Address
+	err := Unmarshal([]byte("10115DE"), &obtained, WithLayout(layout), WithoutHeader())
+	require.ErrorIs(t, err, ErrInvalidHeader)
+	require.EqualError(t, err, "invalid header: missing column City")
+
+	layout.Columns = append(layout.Columns, Column{Name: "City", Start: 7, Width: 6})
+	require.NoError(t, Unmarshal([]byte("10115DEBerlin"), &obtained, WithLayout(layout), WithoutHeader()))
+	assert.Equal(t, []Address{{City: "Berlin", Zip: "10115"}}, obtained)
+	err = Unmarshal([]byte("10115DEBerlin"), &obtained, WithLayout(layout), WithoutHeader(), WithStrictHeaders())
+	require.EqualError(t, err, "invalid header: unknown column Country")
+
+	layout.Columns = slices.Delete(layout.Columns, 0, 2)
+	err = Unmarshal([]byte("       Berlin"), &obtained, WithLayout(layout), WithoutHeader(), WithStrictHeaders())
+	require.EqualError(t, err, "invalid header: missing column Zip")
+}
+
+func TestLoadLayoutJSON(t *testing.T) {
+	layout, err := LoadLayoutJSON(strings.NewReader(`{"columns": [
+		{"name": "Name", "start": 0, "width": 16},
+		{"name": "Amount", "start": 16, "width": 10, "align": "right", "pad": "0", "type": "float"}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Layout{Columns: []Column{
+		{Name: "Name", Start: 0, Width: 16},
+		{Name: "Amount", Start: 16, Width: 10, Align: AlignRight, Pad: "0", Type: TypeFloat},
+	}}, layout)
+
+	tests := []struct {
+		data  string
+		error string
+	}{
+		{
+			data:  `{"columns": []}`,
+			error: "incorrect layout: no columns",
+		},
+		{
+			data:  `{"columns": [{"name": "A", "start": 0, "width": 5}, {"name": "B", "start": 4, "width": 5}]}`,
+			error: "incorrect layout: columns A and B overlap",
+		},
+		{
+			data:  `{"columns": [{"name": "A", "start": 0, "width": 5}, {"name": "A", "start": 5, "width": 5}]}`,
+			error: "incorrect layout: duplicate column A",
+		},
+		{
+			data:  `{"columns": [{"name": "A", "start": 0, "width": 0}]}`,
+			error: "incorrect layout: column A has non-positive width 0",
+		},
+		{
+			data:  `{"columns": [{"name": "A", "start": -1, "width": 1}]}`,
+			error: "incorrect layout: column A has negative start -1",
+		},
+		{
+			data:  `{"columns": [{"start": 0, "width": 1}]}`,
+			error: "incorrect layout: column 0 has no name",
+		},
+		{
+			data:  `{"columns": [{"name": "A", "start": 0, "width": 1, "pad": "ab"}]}`,
+			error: "incorrect layout: column A pad must be a single character",
+		},
+		{
+			data:  `{"columns": [{"name": "A", "start": 0, "width": 1, "align": "center"}]}`,
+			error: `unknown alignment "center"`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := LoadLayoutJSON(strings.NewReader(tt.data))
+		require.EqualError(t, err, tt.error, tt.data)
+	}
+}
+
+func TestLayout_Options(t *testing.T) {
+	var people []LayoutPerson
+	require.ErrorIs(t, Unmarshal(nil, &people, WithoutHeader()), ErrLayoutRequired)
+
+	_, err := Marshal(&people, WithoutHeader(), WithTableStyle(StyleMarkdown))
+	require.EqualError(t, err, "markdown tables require the header line")
+
+	layout, err := LayoutOf(people)
+	require.NoError(t, err)
+	_, err = Marshal(&people, WithLayout(layout), WithTableStyle(StyleASCII))
+	require.EqualError(t, err, "table styles can't be used with a layout")
+}
+
+func TestPadding(t *testing.T) {
+	tests := []struct {
+		value  string
+		padded string
+		align  Alignment
+		pad    rune
+	}{
+		{value: "abc", padded: "abc__", align: AlignLeft, pad: '_'},
+		{value: "abc", padded: "  abc", align: AlignRight, pad: ' '},
+		{value: "-12", padded: "-0012", align: AlignRight, pad: '0'},
+		{value: "+12", padded: "+0012", align: AlignRight, pad: '0'},
+		{value: "0", padded: "00000", align: AlignRight, pad: '0'},
+		{value: "0.5", padded: "000.5", align: AlignRight, pad: '0'},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.padded, padValue(tt.value, 5, tt.align, tt.pad))
+		assert.Equal(t, tt.value, strings.TrimSpace(stripPadding(tt.padded, tt.align, tt.pad)))
+	}
+}
@@ -0,0 +1,26 @@
+package fwencoder
+
+import "reflect"
+
+// FixedWidthMarshaler is implemented by struct types which render their cells without reflection,
+// e.g. by the methods generated with `fwgen -methods`. The encoder calls it on a pointer to every item
+// and takes care of the widths, alignment and table layout.
+type FixedWidthMarshaler interface {
+	// MarshalFixedWidth appends the text of every struct field to buf in order of declaration and the end
+	// offset of every cell in buf to ends. Fields without a column get empty cells.
+	MarshalFixedWidth(buf []byte, ends []int) ([]byte, []int, error)
+}
+
+// FixedWidthUnmarshaler is implemented by struct types which parse their cells without reflection,
+// e.g. by the methods generated with `fwgen -methods`. The decoder calls it on a pointer to a new item.
+type FixedWidthUnmarshaler interface {
+	// UnmarshalFixedWidth sets the struct fields from the raw cells of a line indexed by column position.
+	// columns[i] is the position of the cell of the i-th struct field, -1 if there is none.
+	// Fields without a cell are left untouched.
+	UnmarshalFixedWidth(cells [][]byte, columns []int) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*FixedWidthMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*FixedWidthUnmarshaler)(nil)).Elem()
+)
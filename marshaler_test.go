@@ -0,0 +1,178 @@
+package fwencoder
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/o1egl/fwencoder/internal/codegentest"
+)
+
+// reflectivePayment has the fields and tags of codegentest.Payment, but not its generated methods.
+type reflectivePayment codegentest.Payment
+
+func testPayments() []codegentest.Payment {
+	settled := time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC)
+	reference := "INV-1"
+	retries := 3
+	limit := 0
+	return []codegentest.Payment{
+		{
+			ID: 1, Payer: "John Doe", Currency: "USD", Amount: 12550, Rate: 0.1, Fee: 1.25, Priority: -3, Confirmed: true,
+			Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Settled: &settled, Reference: &reference, Retries: &retries,
+			Limit: &limit, Tags: []string{"a", "b"}, Meta: map[string]int{"y": 2, "x": 1}, Account: codegentest.Account{Bank: "ACME", Number: "42"},
+			Backup: &codegentest.Account{Bank: "Other"}, Discount: 250, Due: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			Labels: []string{"x"}, Memo: "not written", Extra: map[string]string{"Branch": "Main", "Agent": "7"},
+			Note: sql.NullString{String: "first", Valid: true}, Paid: sql.NullTime{Time: settled, Valid: true},
+			Bonus: &sql.Null[int32]{V: 5, Valid: true}, Total: 12675,
+		},
+		{
+			ID: 4294967295, Payer: "Jane", Currency: "EUR", Amount: -1, Rate: 3e-7, Fee: 1e21,
+			Date: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestFixedWidthMarshaler(t *testing.T) {
+	_, ok := any(&codegentest.Payment{}).(FixedWidthMarshaler)
+	require.True(t, ok)
+
+	payments := testPayments()
+	reflective := make([]reflectivePayment, len(payments))
+	for i := range payments {
+		reflective[i] = reflectivePayment(payments[i])
+	}
+
+	trailer := WithTrailer(Trailer{Prefix: "T", Fields: []TrailerField{
+		{Kind: TrailerCount, Start: 1, Width: 4},
+		{Kind: TrailerSum, Column: "amount", Start: 5, Width: 12},
+		{Kind: TrailerSum, Column: "Fee", Start: 17, Width: 30, Decimals: 2},
+	}})
+	optionSets := map[string][]EncoderOption{
+		"default":  nil,
+		"ascii":    {WithTableStyle(StyleASCII)},
+		"columns":  {WithColumns("Fee", "Cur", "Settled", "Backup")},
+		"trailer":  {trailer},
+		"markdown": {WithTableStyle(StyleMarkdown), WithCRLF()},
+	}
+	for name, opts := range optionSets {
+		expected, err := Marshal(&reflective, opts...)
+		require.NoError(t, err, name)
+		obtained, err := Marshal(&payments, opts...)
+		require.NoError(t, err, name)
+		assert.Equal(t, string(expected), string(obtained), name)
+	}
+
+	pointers := []*codegentest.Payment{&payments[0], nil, &payments[1]}
+	reflectivePointers := []*reflectivePayment{&reflective[0], nil, &reflective[1]}
+	expected, err := Marshal(&reflectivePointers)
+	require.NoError(t, err)
+	obtained, err := Marshal(&pointers)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(obtained))
+}
+
+func TestFixedWidthUnmarshaler(t *testing.T) {
+	_, ok := any(&codegentest.Payment{}).(FixedWidthUnmarshaler)
+	require.True(t, ok)
+
+	payments := testPayments()
+	data, err := Marshal(&payments)
+	require.NoError(t, err)
+
+	var obtained []codegentest.Payment
+	require.NoError(t, Unmarshal(data, &obtained))
+	var reflective []reflectivePayment
+	require.NoError(t, Unmarshal(data, &reflective))
+	require.Len(t, obtained, 2)
+	for i := range obtained {
+		assert.Equal(t, reflectivePayment(obtained[i]), reflective[i])
+	}
+	assert.Nil(t, obtained[1].Settled)
+	assert.Nil(t, obtained[1].Retries)
+	assert.Nil(t, obtained[1].Limit)
+	assert.Equal(t, payments[0].Tags, obtained[0].Tags)
+	assert.Equal(t, payments[0].Extra, obtained[0].Extra)
+
+	lines := strings.Split(string(data), "\n")
+	for _, replace := range [][2]string{
+		{"1          John", "x          John"},
+		{"-3      ", "300     "},
+		{"0.1 ", "1e39"},
+		{"true ", "maybe"},
+		{"2024-03-01", "2024-13-01"},
+		{`["a","b"]`, `{"a":"b"}`},
+		{"126.75", "x26.75"},
+	} {
+		row := strings.Replace(lines[1], replace[0], replace[1], 1)
+		require.NotEqual(t, lines[1], row, replace[0])
+		input := []byte(lines[0] + "\n" + row)
+
+		reflectiveErr := Unmarshal(input, &reflective)
+		require.Error(t, reflectiveErr, replace[1])
+		assert.EqualError(t, Unmarshal(input, &obtained), reflectiveErr.Error(), replace[1])
+	}
+}
+
+func benchmarkPayments[T codegentest.Payment | reflectivePayment](n int) []T {
+	payments := testPayments()
+	items := make([]T, n)
+	for i := range items {
+		items[i] = T(payments[i%len(payments)])
+	}
+	return items
+}
+
+func BenchmarkMarshal_Generated(b *testing.B) {
+	items := benchmarkPayments[codegentest.Payment](1000)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := Marshal(&items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshal_Reflective(b *testing.B) {
+	items := benchmarkPayments[reflectivePayment](1000)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := Marshal(&items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_Generated(b *testing.B) {
+	data, err := Marshal(&[]codegentest.Payment{testPayments()[0]})
+	require.NoError(b, err)
+	header, row, _ := strings.Cut(string(data), "\n")
+	data = []byte(header + strings.Repeat("\n"+row, 1000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		var items []codegentest.Payment
+		if err := Unmarshal(data, &items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_Reflective(b *testing.B) {
+	data, err := Marshal(&[]codegentest.Payment{testPayments()[0]})
+	require.NoError(b, err)
+	header, row, _ := strings.Cut(string(data), "\n")
+	data = []byte(header + strings.Repeat("\n"+row, 1000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		var items []reflectivePayment
+		if err := Unmarshal(data, &items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
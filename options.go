@@ -0,0 +1,356 @@
+package fwencoder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// EncoderOption configures the behavior of Marshal and MarshalWriter.
+type EncoderOption interface {
+	applyEncoder(o *encoderOptions)
+}
+
+// DecoderOption configures the behavior of Unmarshal and UnmarshalReader.
+type DecoderOption interface {
+	applyDecoder(o *decoderOptions)
+}
+
+// Option configures both the encoder and the decoder.
+type Option interface {
+	EncoderOption
+	DecoderOption
+}
+
+type encoderOptionFunc func(*encoderOptions)
+
+func (f encoderOptionFunc) applyEncoder(o *encoderOptions) {
+	f(o)
+}
+
+type decoderOptionFunc func(*decoderOptions)
+
+func (f decoderOptionFunc) applyDecoder(o *decoderOptions) {
+	f(o)
+}
+
+type option struct {
+	encoder encoderOptionFunc
+	decoder decoderOptionFunc
+}
+
+func (opt option) applyEncoder(o *encoderOptions) {
+	opt.encoder(o)
+}
+
+func (opt option) applyDecoder(o *decoderOptions) {
+	opt.decoder(o)
+}
+
+type encoderOptions struct {
+	style          TableStyle
+	border         *tableBorder
+	lineTerminator string
+	bom            bool
+	trailer        *Trailer
+	columns        []string
+	layout         *Layout
+	header         bool
+	separator      *string
+	finalNewline   bool
+}
+
+func newEncoderOptions(opts []EncoderOption) *encoderOptions {
+	o := &encoderOptions{
+		style:          StylePlain,
+		lineTerminator: "\n",
+		header:         true,
+	}
+	for _, opt := range opts {
+		opt.applyEncoder(o)
+	}
+	return o
+}
+
+func (o *encoderOptions) validate() error {
+	var err error
+	if o.border, err = getTableBorder(o.style); err != nil {
+		return err
+	}
+	if err := validateLineTerminator(o.lineTerminator); err != nil {
+		return err
+	}
+	if o.trailer != nil {
+		if err := o.trailer.validate(); err != nil {
+			return err
+		}
+	}
+	if o.separator != nil {
+		if o.layout != nil {
+			return errors.New("WithSeparator can't be used with WithLayout, the layout sets the column positions")
+		}
+		if o.style != StylePlain {
+			return errors.New("separator can only be used with the plain table style")
+		}
+		border := *o.border
+		border.rowSep = *o.separator
+		o.border = &border
+	}
+	if o.layout != nil {
+		if o.style != StylePlain {
+			return errors.New("table styles can't be used with a layout")
+		}
+		return o.layout.Validate()
+	}
+	if !o.header && o.style == StyleMarkdown {
+		return errors.New("markdown tables require the header line")
+	}
+	return nil
+}
+
+func validateLineTerminator(terminator string) error {
+	switch terminator {
+	case "\n", "\r\n", "\r":
+		return nil
+	}
+	return fmt.Errorf("unsupported line terminator %q", terminator)
+}
+
+// WithTableStyle sets the style used to render the table. StylePlain is used by default.
+func WithTableStyle(style TableStyle) EncoderOption {
+	return encoderOptionFunc(func(o *encoderOptions) {
+		o.style = style
+	})
+}
+
+// WithCRLF makes the encoder terminate lines with \r\n instead of \n. It's a shorthand for WithLineTerminator("\r\n").
+func WithCRLF() EncoderOption {
+	return WithLineTerminator("\r\n")
+}
+
+// WithLineTerminator sets the line terminator written by the encoder: \n (default), \r\n or \r.
+// The decoder accepts all of them.
+func WithLineTerminator(terminator string) EncoderOption {
+	return encoderOptionFunc(func(o *encoderOptions) {
+		o.lineTerminator = terminator
+	})
+}
+
+// WithFinalNewline makes the encoder terminate the last line, by default the line terminator only separates lines.
+func WithFinalNewline() EncoderOption {
+	return encoderOptionFunc(func(o *encoderOptions) {
+		o.finalNewline = true
+	})
+}
+
+// WithSeparator sets the string written between columns of plain tables, a single space by default.
+// It can be empty, several spaces or contain other characters like " | ".
+// The decoder finds columns of structs by the header names regardless of the separator, but it needs
+// the separator to discover the columns of records and maps unless it consists of spaces.
+func WithSeparator(separator string) Option {
+	return option{
+		encoder: func(o *encoderOptions) {
+			o.separator = &separator
+		},
+		decoder: func(o *decoderOptions) {
+			o.separator = separator
+		},
+	}
+}
+
+// WithBOM makes the encoder write a UTF-8 byte order mark before the data.
+func WithBOM() EncoderOption {
+	return encoderOptionFunc(func(o *encoderOptions) {
+		o.bom = true
+	})
+}
+
+// WithColumns sets the columns written by the encoder and their order.
+// By default, struct fields are written in order of declaration, records in order of their columns and maps
+// in order of sorted keys.
+func WithColumns(columns ...string) EncoderOption {
+	return encoderOptionFunc(func(o *encoderOptions) {
+		o.columns = columns
+	})
+}
+
+type decoderOptions struct {
+	padShortLines       bool
+	openEndedLastColumn bool
+	truncateLongLines   bool
+	skipLines           int
+	skipBlankLines      bool
+	commentPrefixes     []string
+	commentPattern      *regexp.Regexp
+	headerSearchLines   int
+	trailer             *Trailer
+	layout              *Layout
+	header              bool
+	separator           string
+	lenientHeaders      bool
+	strictHeaders       bool
+	emptyAsZero         bool
+	headerMatch         func(column, header string)
+}
+
+func newDecoderOptions(opts []DecoderOption) *decoderOptions {
+	o := &decoderOptions{
+		header: true,
+	}
+	for _, opt := range opts {
+		opt.applyDecoder(o)
+	}
+	return o
+}
+
+func (o *decoderOptions) validate() error {
+	if o.trailer != nil {
+		if err := o.trailer.validate(); err != nil {
+			return err
+		}
+	}
+	if o.layout != nil {
+		return o.layout.Validate()
+	}
+	if !o.header {
+		return ErrLayoutRequired
+	}
+	return nil
+}
+
+// WithPadShortLines makes the decoder right-pad lines that are shorter than the header line with spaces
+// instead of returning an error. It is useful when trailing whitespace was trimmed by an editor or transfer tool.
+func WithPadShortLines() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.padShortLines = true
+	})
+}
+
+// WithOpenEndedLastColumn makes the column that ends the header line consume everything up to the end of a data line,
+// so data lines may be longer or shorter than the header line.
+func WithOpenEndedLastColumn() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.openEndedLastColumn = true
+	})
+}
+
+// WithTruncateLongLines makes the decoder drop data beyond the header line length instead of returning an error.
+func WithTruncateLongLines() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.truncateLongLines = true
+	})
+}
+
+// WithSkipLines makes the decoder ignore the first n lines of the input, e.g. a report title block.
+func WithSkipLines(n int) DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.skipLines = n
+	})
+}
+
+// WithSkipBlankLines makes the decoder ignore empty lines and lines consisting of whitespace only.
+func WithSkipBlankLines() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.skipBlankLines = true
+	})
+}
+
+// WithCommentPrefix makes the decoder ignore lines starting with any of the given prefixes.
+func WithCommentPrefix(prefixes ...string) DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.commentPrefixes = append(o.commentPrefixes, prefixes...)
+	})
+}
+
+// WithCommentPattern makes the decoder ignore lines matching the regular expression.
+func WithCommentPattern(re *regexp.Regexp) DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.commentPattern = re
+	})
+}
+
+// WithHeaderSearch makes the decoder look for the header line within the first n lines which are not skipped.
+// The header line is the first line containing all column names of the struct. Lines before it are ignored.
+// If no such line is found, ErrHeaderNotFound is returned. Records and maps have no column names to look for,
+// they can be decoded with header search only together with WithLayout.
+func WithHeaderSearch(n int) DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.headerSearchLines = n
+	})
+}
+
+// WithTrailer declares the trailer line of the data.
+// The decoder excludes the trailer from the data and verifies the record count and control totals against the decoded
+// records, returning ErrTrailerNotFound or ErrTrailerMismatch on failure. The encoder writes the trailer after the data.
+func WithTrailer(trailer Trailer) Option {
+	return option{
+		encoder: func(o *encoderOptions) {
+			o.trailer = &trailer
+		},
+		decoder: func(o *decoderOptions) {
+			o.trailer = &trailer
+		},
+	}
+}
+
+// WithLayout sets the column positions instead of deriving them from the header line.
+// The decoder expects the header line unless WithoutHeader is set, but only uses it to locate the data.
+// The encoder writes values at the layout positions, aligned and padded as defined by the columns.
+func WithLayout(layout *Layout) Option {
+	return option{
+		encoder: func(o *encoderOptions) {
+			o.layout = layout
+		},
+		decoder: func(o *decoderOptions) {
+			o.layout = layout
+		},
+	}
+}
+
+// WithoutHeader declares data without the header line. The decoder requires the column positions to be set
+// with WithLayout, the encoder fits table columns to their values then.
+func WithoutHeader() Option {
+	return option{
+		encoder: func(o *encoderOptions) {
+			o.header = false
+		},
+		decoder: func(o *decoderOptions) {
+			o.header = false
+		},
+	}
+}
+
+// WithLenientHeaders makes the decoder match header names of struct columns and their aliases ignoring differences
+// in case and whitespace, e.g. "Zip Code" matches "ZIP  CODE" and "ZIPCODE".
+func WithLenientHeaders() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.lenientHeaders = true
+	})
+}
+
+// WithHeaderMatchFunc sets the function called for every struct column found in the header line
+// with the column name and the header text it matched, e.g. one of the aliases.
+func WithHeaderMatchFunc(f func(column, header string)) DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.headerMatch = f
+	})
+}
+
+// WithStrictHeaders makes the decoder validate the header line: every struct column must be present and appear once,
+// the columns must not overlap and the header must not contain unknown columns. Otherwise ErrInvalidHeader is returned.
+// Records and maps are only checked for duplicate columns. Without strict mode only the fields tagged
+// with the required option, e.g. `fw:"Name,required"`, must be present. With WithLayout the layout columns are
+// validated instead of the header line.
+func WithStrictHeaders() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.strictHeaders = true
+	})
+}
+
+// WithEmptyAsZero makes the decoder leave the zero value in fields of empty cells instead of parsing them,
+// e.g. an empty cell of an int field is 0 rather than a parsing error. Empty cells of pointer fields are always nil.
+func WithEmptyAsZero() DecoderOption {
+	return decoderOptionFunc(func(o *decoderOptions) {
+		o.emptyAsZero = true
+	})
+}
@@ -0,0 +1,171 @@
+package fwencoder
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typePlan is the compiled reflective handling of a struct type: column names, parsed tags and field setters.
+// Plans are built once per type and shared by all calls and rows, see planOf.
+type typePlan struct {
+	columns []string       // column names in order of the struct fields
+	fields  []fieldPlan    // fields[i] is mapped to columns[i], ignored fields are left out
+	index   map[string]int // column name -> index in fields, the first field wins
+	extra   *fieldPlan     // the field tagged with the extra option, it has no column of its own
+
+	headerPatterns  []*regexp.Regexp // headerPatterns[i] locates columns[i] or its aliases in the header line
+	lenientPatterns []*regexp.Regexp // headerPatterns ignoring case and whitespace
+	headerErr       error            // the error of compiling the header patterns
+	tagErr          error            // the first error of parsing the fw tags, reported by the decoder
+	marshal         bool             // the type implements FixedWidthMarshaler
+	unmarshal       bool             // the type implements FixedWidthUnmarshaler
+
+	// extraLine is the last header line extra map fields were decoded from, maps don't keep the column order
+	extraLine atomic.Pointer[[]string]
+}
+
+type fieldPlan struct {
+	reflect.StructField
+	name      string
+	set       fieldSetter
+	tag       *fwTag // the parsed fw tag, nil if it's invalid
+	tagErr    error  // the error of parsing the fw tag, reported by the encoder if the column is written
+	omitEmpty bool   // zero values are written as empty cells and empty cells are left zero
+	sqlValue  bool   // the values may be sql null types or implement driver.Valuer
+}
+
+// fieldSetter parses the raw cell into the field.
+type fieldSetter func(field reflect.Value, rawValue []byte) error
+
+// typePlans caches *typePlan by reflect.Type.
+var typePlans sync.Map
+
+func planOf(t reflect.Type) *typePlan {
+	if plan, ok := typePlans.Load(t); ok {
+		return plan.(*typePlan)
+	}
+	plan, _ := typePlans.LoadOrStore(t, compilePlan(t))
+	return plan.(*typePlan)
+}
+
+func compilePlan(t reflect.Type) *typePlan {
+	plan := &typePlan{
+		columns:   make([]string, 0, t.NumField()),
+		fields:    make([]fieldPlan, 0, t.NumField()),
+		index:     make(map[string]int, t.NumField()),
+		marshal:   reflect.PointerTo(t).Implements(marshalerType),
+		unmarshal: reflect.PointerTo(t).Implements(unmarshalerType),
+	}
+	for i := range t.NumField() {
+		f := fieldPlan{StructField: t.Field(i)}
+		if isIgnoredField(&f.StructField) {
+			continue
+		}
+		f.name = getRefName(&f.StructField)
+		f.tag, f.tagErr = parseFwTag(&f.StructField)
+		if f.tag != nil && f.tag.extra {
+			if f.Type != stringMapType && f.Type != recordType {
+				f.tagErr = fmt.Errorf("field %s: extra columns require map[string]string or fwencoder.Record, got %v", f.Name, f.Type)
+			}
+			plan.extra = &f
+			plan.tagErr = cmp.Or(plan.tagErr, f.tagErr)
+			continue
+		}
+		plan.tagErr = cmp.Or(plan.tagErr, f.tagErr)
+		f.omitEmpty = hasOmitEmpty(&f.StructField, f.tag)
+		f.set = newFieldSetter(&f.StructField, f.tag, f.omitEmpty)
+		f.sqlValue = hasSQLValue(f.Type)
+		if _, ok := plan.index[f.name]; !ok {
+			plan.index[f.name] = len(plan.fields)
+		}
+		plan.fields = append(plan.fields, f)
+		plan.columns = append(plan.columns, f.name)
+	}
+	names := make([][]string, len(plan.fields))
+	for i := range plan.fields {
+		names[i] = []string{plan.columns[i]}
+		if tag := plan.fields[i].tag; tag != nil {
+			names[i] = append(names[i], tag.aliases...)
+		}
+	}
+	if plan.headerPatterns, plan.headerErr = compileHeaderPatterns(names, false); plan.headerErr == nil {
+		plan.lenientPatterns, plan.headerErr = compileHeaderPatterns(names, true)
+	}
+	return plan
+}
+
+// isIgnoredField reports whether the field has no column: it's unexported or its column name is "-".
+func isIgnoredField(field *reflect.StructField) bool {
+	return !field.IsExported() || getRefName(field) == "-"
+}
+
+// hasOmitEmpty reports whether the field has the omitempty flag in the fw tag or the omitempty option in the json tag.
+func hasOmitEmpty(field *reflect.StructField, tag *fwTag) bool {
+	if tag != nil && tag.omitEmpty {
+		return true
+	}
+	_, opts, _ := strings.Cut(field.Tag.Get(jsonTagName), ",")
+	return slices.Contains(strings.Split(opts, ","), "omitempty")
+}
+
+// typedSetter parses the trimmed non-empty cell into the field of the type it was chosen for, isPointer reports
+// whether the field is a pointer to this type.
+type typedSetter func(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error
+
+// newFieldSetter chooses the conversion of raw cells by the field type.
+// Null cells and empty cells of pointer, sql null and omitempty fields leave the zero value.
+func newFieldSetter(structField *reflect.StructField, tag *fwTag, omitEmpty bool) fieldSetter {
+	fieldType := structField.Type
+	isPointer := fieldType.Kind() == reflect.Ptr
+	if isPointer {
+		fieldType = fieldType.Elem()
+	}
+
+	set := newSQLSetter(structField, fieldType)
+	nullable := set != nil || isPointer
+	if set == nil {
+		set = newKindSetter(fieldType)
+	}
+	return func(field reflect.Value, rawValue []byte) error {
+		rawValue = bytes.TrimSpace(rawValue)
+		if len(rawValue) == 0 && (omitEmpty || nullable) || tag.isNull(rawValue) {
+			return nil
+		}
+		return set(field, structField, rawValue, isPointer)
+	}
+}
+
+// newKindSetter chooses the conversion of raw cells by the kind of the type.
+func newKindSetter(fieldType reflect.Type) typedSetter {
+	switch fieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return setIntFieldValue
+	case reflect.Float32, reflect.Float64:
+		return setFloatFieldValue
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return setUintFieldValue
+	case reflect.String:
+		return func(field reflect.Value, _ *reflect.StructField, rawValue []byte, isPointer bool) error {
+			return setStringFieldValue(field, rawValue, isPointer)
+		}
+	case reflect.Bool:
+		return setBoolFieldValue
+	case reflect.Struct:
+		if fieldType == timeType {
+			return setTimeFieldValue
+		}
+	}
+	return func(field reflect.Value, structField *reflect.StructField, rawValue []byte, _ bool) error {
+		return setJSONFieldValue(field, structField, rawValue)
+	}
+}
@@ -0,0 +1,46 @@
+package fwencoder
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanOf(t *testing.T) {
+	type Item struct {
+		Name    string `fw:"N,width=4"`
+		Amount  int    `column:"Sum"`
+		Comment string `json:"Note"`
+		Other   string `column:"Sum"`
+	}
+	itemType := reflect.TypeOf(Item{})
+
+	plans := make([]*typePlan, 8)
+	var wg sync.WaitGroup
+	for i := range plans {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plans[i] = planOf(itemType)
+		}()
+	}
+	wg.Wait()
+	for _, plan := range plans {
+		require.Same(t, plans[0], plan)
+	}
+
+	plan := plans[0]
+	assert.Equal(t, []string{"N", "Sum", "Note", "Sum"}, plan.columns)
+	assert.Equal(t, map[string]int{"N": 0, "Sum": 1, "Note": 2}, plan.index)
+	assert.False(t, plan.marshal)
+	assert.False(t, plan.unmarshal)
+
+	var item Item
+	require.NoError(t, plan.fields[1].set(reflect.ValueOf(&item).Elem().Field(1), []byte(" 42 ")))
+	assert.Equal(t, 42, item.Amount)
+	require.EqualError(t, plan.fields[1].set(reflect.ValueOf(&item).Elem().Field(1), []byte("x")),
+		`filed casting "x" to "Amount:int": strconv.ParseInt: parsing "x": invalid syntax`)
+}
@@ -0,0 +1,386 @@
+package fwencoder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrUnknownColumn is returned when a record has no column with the requested name
+var ErrUnknownColumn = errors.New("unknown column")
+
+// Record is a dynamic row of fixed width data. It keeps the column order of the header line and gives access to
+// raw cells and typed values by column name. Decode into *[]Record when the columns aren't known at compile time.
+type Record struct {
+	header *recordHeader
+	values []string
+}
+
+// NewRecord creates a record with the given columns and values, e.g. a row of a query result.
+// The number of values must match the number of columns.
+func NewRecord(columns, values []string) (Record, error) {
+	if len(columns) != len(values) {
+		return Record{}, fmt.Errorf("got %d values for %d columns", len(values), len(columns))
+	}
+	return Record{header: newRecordHeader(columns), values: values}, nil
+}
+
+// recordHeader is shared by all records of the same input.
+type recordHeader struct {
+	columns []string
+	index   map[string]int
+	line    []string // all columns of the header line of records decoded by an extra field, in order
+}
+
+func newRecordHeader(columns []string) *recordHeader {
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[c] = i
+	}
+	return &recordHeader{columns: columns, index: index}
+}
+
+// Columns returns the column names in order of appearance.
+func (r Record) Columns() []string {
+	if r.header == nil {
+		return nil
+	}
+	return r.header.columns
+}
+
+// Len returns the number of cells in the record.
+func (r Record) Len() int {
+	return len(r.values)
+}
+
+// Raw returns the cell of the column as it is, including padding.
+func (r Record) Raw(column string) (string, bool) {
+	if r.header == nil {
+		return "", false
+	}
+	i, ok := r.header.index[column]
+	if !ok {
+		return "", false
+	}
+	return r.values[i], true
+}
+
+// Get returns the cell of the column with leading and trailing whitespace removed.
+func (r Record) Get(column string) (string, bool) {
+	value, ok := r.Raw(column)
+	return strings.TrimSpace(value), ok
+}
+
+// String returns the trimmed cell of the column or an empty string if there is no such column.
+func (r Record) String(column string) string {
+	value, _ := r.Get(column)
+	return value
+}
+
+// Int parses the cell of the column as a base 10 integer.
+func (r Record) Int(column string) (int64, error) {
+	value, err := r.lookup(column)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, newColumnCastingError(err, value, column)
+	}
+	return i, nil
+}
+
+// Uint parses the cell of the column as a base 10 unsigned integer.
+func (r Record) Uint(column string) (uint64, error) {
+	value, err := r.lookup(column)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, newColumnCastingError(err, value, column)
+	}
+	return i, nil
+}
+
+// Float parses the cell of the column as a floating point number.
+func (r Record) Float(column string) (float64, error) {
+	value, err := r.lookup(column)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, newColumnCastingError(err, value, column)
+	}
+	return f, nil
+}
+
+// Bool parses the cell of the column as a boolean value, see strconv.ParseBool.
+func (r Record) Bool(column string) (bool, error) {
+	value, err := r.lookup(column)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newColumnCastingError(err, value, column)
+	}
+	return b, nil
+}
+
+// Time parses the cell of the column as a time using the layout, see time.Parse.
+func (r Record) Time(column, layout string) (time.Time, error) {
+	value, err := r.lookup(column)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, newColumnCastingError(err, value, column)
+	}
+	return t, nil
+}
+
+// Map returns the trimmed cells indexed by column name.
+func (r Record) Map() map[string]string {
+	m := make(map[string]string, len(r.values))
+	for i, c := range r.Columns() {
+		m[c] = strings.TrimSpace(r.values[i])
+	}
+	return m
+}
+
+func (r Record) lookup(column string) (string, error) {
+	value, ok := r.Get(column)
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownColumn, column)
+	}
+	return value, nil
+}
+
+func newColumnCastingError(err error, rawValue, column string) error {
+	return fmt.Errorf(`filed casting "%s" of column %s: %w`, rawValue, column, err)
+}
+
+var (
+	recordType    = reflect.TypeOf(Record{})
+	stringMapType = reflect.TypeOf(map[string]string{})
+)
+
+// dynamicDecodeTarget decodes rows into *[]Record or *[]map[string]string discovering the columns from the header line.
+type dynamicDecodeTarget struct {
+	slice         reflect.Value
+	separator     string
+	strictHeaders bool
+	header        *recordHeader
+}
+
+func newDynamicDecodeTarget(v any, options *decoderOptions) (*dynamicDecodeTarget, bool) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return nil, false
+	}
+	if itemType := t.Elem().Elem(); itemType != recordType && itemType != stringMapType {
+		return nil, false
+	}
+	slice := reflect.ValueOf(v).Elem()
+	slice.Set(slice.Slice(0, 0))
+	return &dynamicDecodeTarget{slice: slice, separator: options.separator, strictHeaders: options.strictHeaders}, true
+}
+
+func (t *dynamicDecodeTarget) parseHeader(headerLine string) (columns []fwColumn, complete bool, err error) {
+	if strings.TrimSpace(t.separator) != "" {
+		columns = splitHeaders(headerLine, t.separator)
+	} else {
+		columns = discoverHeaders(headerLine)
+	}
+	t.setColumns(columns)
+	return columns, len(columns) > 0, nil
+}
+
+func (t *dynamicDecodeTarget) setColumns(columns []fwColumn) {
+	names := make([]string, len(columns))
+	for i := range columns {
+		names[i] = columns[i].name
+	}
+	t.header = newRecordHeader(names)
+}
+
+func (t *dynamicDecodeTarget) appendRow(cells [][]byte) error {
+	record := Record{header: t.header, values: make([]string, len(cells))}
+	for i, cell := range cells {
+		record.values[i] = string(cell)
+	}
+	var item reflect.Value
+	if t.slice.Type().Elem() == recordType {
+		item = reflect.ValueOf(record)
+	} else {
+		item = reflect.ValueOf(record.Map())
+	}
+	t.slice.Set(reflect.Append(t.slice, item))
+	return nil
+}
+
+func (t *dynamicDecodeTarget) recordCount() int {
+	return t.slice.Len()
+}
+
+func (t *dynamicDecodeTarget) sumColumn(column string, decimals int) (int64, error) {
+	if t.slice.Type().Elem() == recordType {
+		return newRecordEncodeSource(t.slice, t.header.columns).sumColumn(column, decimals)
+	}
+	return newMapEncodeSource(t.slice, t.header.columns).sumColumn(column, decimals)
+}
+
+// recordEncodeSource encodes a slice of records. By default, columns are written in order of appearance.
+type recordEncodeSource struct {
+	records []Record
+	columns []string
+}
+
+func newRecordEncodeSource(slice reflect.Value, columns []string) *recordEncodeSource {
+	records := make([]Record, slice.Len())
+	for i := range records {
+		records[i] = slice.Index(i).Interface().(Record)
+	}
+	if columns == nil {
+		seen := make(map[string]bool)
+		for _, r := range records {
+			for _, c := range r.Columns() {
+				if !seen[c] {
+					seen[c] = true
+					columns = append(columns, c)
+				}
+			}
+		}
+	}
+	return &recordEncodeSource{records: records, columns: columns}
+}
+
+func (s *recordEncodeSource) columnNames() []string {
+	return s.columns
+}
+
+func (s *recordEncodeSource) columnField(int) *fieldPlan {
+	return nil
+}
+
+func (s *recordEncodeSource) cell(row, column int) (reflect.Value, *fieldPlan, error) {
+	value, ok := s.records[row].Get(s.columns[column])
+	if !ok {
+		return reflect.Value{}, nil, nil
+	}
+	return reflect.ValueOf(value), nil, nil
+}
+
+func (s *recordEncodeSource) recordCount() int {
+	return len(s.records)
+}
+
+func (s *recordEncodeSource) sumColumn(column string, decimals int) (int64, error) {
+	return sumCells(s, column, decimals)
+}
+
+// mapEncodeSource encodes a slice of maps with string keys. By default, columns are sorted by name.
+type mapEncodeSource struct {
+	slice   reflect.Value
+	columns []string
+	keys    []reflect.Value // keys[i] is the map key of columns[i]
+}
+
+func newMapEncodeSource(slice reflect.Value, columns []string) *mapEncodeSource {
+	if columns == nil {
+		seen := make(map[string]bool)
+		for i := range slice.Len() {
+			iter := slice.Index(i).MapRange()
+			for iter.Next() {
+				if c := iter.Key().String(); !seen[c] {
+					seen[c] = true
+					columns = append(columns, c)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+	keyType := slice.Type().Elem().Key()
+	keys := make([]reflect.Value, len(columns))
+	for i, c := range columns {
+		keys[i] = reflect.ValueOf(c).Convert(keyType)
+	}
+	return &mapEncodeSource{slice: slice, columns: columns, keys: keys}
+}
+
+func (s *mapEncodeSource) columnNames() []string {
+	return s.columns
+}
+
+func (s *mapEncodeSource) columnField(int) *fieldPlan {
+	return nil
+}
+
+func (s *mapEncodeSource) cell(row, column int) (reflect.Value, *fieldPlan, error) {
+	return s.slice.Index(row).MapIndex(s.keys[column]), nil, nil
+}
+
+func (s *mapEncodeSource) recordCount() int {
+	return s.slice.Len()
+}
+
+func (s *mapEncodeSource) sumColumn(column string, decimals int) (int64, error) {
+	return sumCells(s, column, decimals)
+}
+
+// discoverHeaders splits the header line into columns. Every word separated by whitespace starts a new column
+// which spans up to the start of the next one, so column names can't contain spaces.
+func discoverHeaders(headerLine string) []fwColumn {
+	var columns []fwColumn
+	prevIsSpace := true
+	pos := 0
+	for _, r := range headerLine {
+		isSpace := unicode.IsSpace(r)
+		if prevIsSpace && !isSpace {
+			start := pos
+			if len(columns) > 0 {
+				columns[len(columns)-1].end = pos
+			} else {
+				// the first column may be right aligned
+				start = 0
+			}
+			columns = append(columns, fwColumn{start: start})
+		}
+		prevIsSpace = isSpace
+		pos++
+	}
+	if len(columns) > 0 {
+		columns[len(columns)-1].end = pos
+	}
+
+	lineRunes := []rune(headerLine)
+	for i := range columns {
+		columns[i].name = strings.TrimSpace(string(lineRunes[columns[i].start:columns[i].end]))
+	}
+	return columns
+}
+
+// splitHeaders splits the header line into columns at the separator. The separator doesn't belong to the columns.
+func splitHeaders(headerLine, separator string) []fwColumn {
+	var columns []fwColumn
+	start := 0 // in characters
+	for {
+		name, rest, found := strings.Cut(headerLine, separator)
+		end := start + utf8.RuneCountInString(name)
+		columns = append(columns, fwColumn{name: strings.TrimSpace(name), start: start, end: end})
+		if !found {
+			return columns
+		}
+		headerLine = rest
+		start = end + utf8.RuneCountInString(separator)
+	}
+}
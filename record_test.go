@@ -0,0 +1,146 @@
+package fwencoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dynamicData = `Name            Postcode Birthday Amount
+Evan Whitehouse 3122     19870101 10.5  
+Chuck Norris    77868    19651203 x     `
+
+func TestUnmarshal_Records(t *testing.T) {
+	var records []Record
+	require.NoError(t, Unmarshal([]byte(dynamicData), &records))
+	require.Len(t, records, 2)
+
+	r := records[0]
+	assert.Equal(t, []string{"Name", "Postcode", "Birthday", "Amount"}, r.Columns())
+	assert.Equal(t, 4, r.Len())
+
+	raw, ok := r.Raw("Name")
+	assert.True(t, ok)
+	assert.Equal(t, "Evan Whitehouse ", raw)
+	assert.Equal(t, "Evan Whitehouse", r.String("Name"))
+
+	postcode, err := r.Int("Postcode")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3122), postcode)
+
+	upostcode, err := r.Uint("Postcode")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3122), upostcode)
+
+	bday, err := r.Time("Birthday", "20060102")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(1987, 1, 1, 0, 0, 0, 0, time.UTC), bday)
+
+	amount, err := r.Float("Amount")
+	require.NoError(t, err)
+	assert.InDelta(t, 10.5, amount, 0)
+
+	_, err = records[1].Float("Amount")
+	require.EqualError(t, err, `filed casting "x" of column Amount: strconv.ParseFloat: parsing "x": invalid syntax`)
+
+	_, err = r.Bool("Phone")
+	require.ErrorIs(t, err, ErrUnknownColumn)
+
+	_, ok = r.Get("Phone")
+	assert.False(t, ok)
+
+	assert.Equal(t, map[string]string{
+		"Name":     "Chuck Norris",
+		"Postcode": "77868",
+		"Birthday": "19651203",
+		"Amount":   "x",
+	}, records[1].Map())
+}
+
+func TestUnmarshal_Maps(t *testing.T) {
+	var maps []map[string]string
+	require.NoError(t, Unmarshal([]byte(dynamicData), &maps))
+	assert.Equal(t, []map[string]string{
+		{"Name": "Evan Whitehouse", "Postcode": "3122", "Birthday": "19870101", "Amount": "10.5"},
+		{"Name": "Chuck Norris", "Postcode": "77868", "Birthday": "19651203", "Amount": "x"},
+	}, maps)
+
+	trailer := Trailer{
+		Prefix: "TRL",
+		Fields: []TrailerField{
+			{Kind: TrailerCount, Start: 4, Width: 3},
+			{Kind: TrailerSum, Column: "Amount", Start: 8, Width: 5, Decimals: 1},
+		},
+	}
+	data := "  ID Amount\n   1 10.5  \n   2 1     \nTRL 002 00115"
+	require.NoError(t, Unmarshal([]byte(data), &maps, WithTrailer(trailer)))
+	assert.Equal(t, []map[string]string{{"ID": "1", "Amount": "10.5"}, {"ID": "2", "Amount": "1"}}, maps)
+
+	var records []Record
+	require.NoError(t, Unmarshal([]byte(data), &records, WithTrailer(trailer)))
+	require.Len(t, records, 2)
+	err := Unmarshal([]byte(strings.Replace(data, "00115", "00116", 1)), &records, WithTrailer(trailer))
+	require.ErrorIs(t, err, ErrTrailerMismatch)
+}
+
+func TestMarshal_Maps(t *testing.T) {
+	rows := []map[string]any{
+		{"Name": "John", "Age": 20, "Birthday": time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"Name": "Jane", "Tags": []string{"a"}},
+		nil,
+	}
+
+	b, err := Marshal(&rows)
+	require.NoError(t, err)
+	assert.Equal(t, "Age Birthday             Name Tags \n"+
+		"20  2000-01-02T00:00:00Z John      \n"+
+		`                         Jane ["a"]`+"\n"+
+		"                                   ", string(b))
+
+	b, err = Marshal(&rows, WithColumns("Name", "Age"))
+	require.NoError(t, err)
+	assert.Equal(t, "Name Age\nJohn 20 \nJane    \n        ", string(b))
+
+	trailer := Trailer{Prefix: "T", Fields: []TrailerField{{Kind: TrailerSum, Column: "Age", Start: 1, Width: 3}}}
+	b, err = Marshal(&[]map[string]string{{"Age": "1"}, {"Age": "2"}}, WithTrailer(trailer))
+	require.NoError(t, err)
+	assert.Equal(t, "Age\n1  \n2  \nT003", string(b))
+}
+
+func TestMarshal_Records(t *testing.T) {
+	r1, err := NewRecord([]string{"Name", "Postcode"}, []string{"Evan", "3122"})
+	require.NoError(t, err)
+	r2, err := NewRecord([]string{"Name", "Phone"}, []string{"Chuck", "(713) 868-6003"})
+	require.NoError(t, err)
+
+	b, err := Marshal(&[]Record{r1, r2})
+	require.NoError(t, err)
+	assert.Equal(t, "Name  Postcode Phone         \nEvan  3122                   \nChuck          (713) 868-6003", string(b))
+
+	b, err = Marshal(&[]Record{r1, r2}, WithColumns("Phone", "Name"))
+	require.NoError(t, err)
+	assert.Equal(t, "Phone          Name \n               Evan \n(713) 868-6003 Chuck", string(b))
+
+	var records []Record
+	require.NoError(t, Unmarshal(b, &records))
+	assert.Equal(t, "Chuck", records[1].String("Name"))
+
+	_, err = NewRecord([]string{"Name"}, nil)
+	require.EqualError(t, err, "got 0 values for 1 columns")
+}
+
+func TestMarshal_StructColumns(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+	b, err := Marshal(&[]Person{{"John", 20}}, WithColumns("Age", "Name"))
+	require.NoError(t, err)
+	assert.Equal(t, "Age Name\n20  John", string(b))
+
+	_, err = Marshal(&[]Person{{"John", 20}}, WithColumns("Phone"))
+	require.ErrorIs(t, err, ErrUnknownColumn)
+}
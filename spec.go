@@ -0,0 +1,347 @@
+package fwencoder
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PositionBase defines how positions are counted in a layout spec.
+type PositionBase int
+
+const (
+	// BaseAuto detects the position base from the smallest start position: specs starting at 1 are treated
+	// as 1-based, all others, including specs without start positions, as 0-based. Specs which don't start
+	// at the first character, e.g. documentation of a layout with a leading gap, need an explicit base.
+	BaseAuto PositionBase = iota
+	// BaseZero is used by specs counting positions from 0.
+	BaseZero
+	// BaseOne is used by specs counting positions from 1.
+	BaseOne
+)
+
+// SpecOptions configures loading of layout specs.
+type SpecOptions struct {
+	// Base is the position base of the start and end columns, BaseAuto by default
+	Base PositionBase
+	// AllowGaps allows unused characters between columns, otherwise a gap is reported as an error
+	AllowGaps bool
+	// Comma is the field delimiter of CSV specs, ',' by default
+	Comma rune
+}
+
+// specKeys maps normalized spec table headers to the column attributes.
+var specKeys = map[string]string{
+	"name":        "name",
+	"field":       "name",
+	"fieldname":   "name",
+	"column":      "name",
+	"columnname":  "name",
+	"start":       "start",
+	"from":        "start",
+	"position":    "start",
+	"pos":         "start",
+	"end":         "end",
+	"to":          "end",
+	"length":      "length",
+	"len":         "length",
+	"width":       "length",
+	"size":        "length",
+	"type":        "type",
+	"datatype":    "type",
+	"format":      "format",
+	"align":       "align",
+	"alignment":   "align",
+	"pad":         "pad",
+	"padding":     "pad",
+	"justify":     "align",
+	"description": "description",
+	"comment":     "description",
+}
+
+// specTypes maps vendor type names to column types.
+var specTypes = map[string]ColumnType{
+	"":             TypeString,
+	"a":            TypeString,
+	"an":           TypeString,
+	"x":            TypeString,
+	"alpha":        TypeString,
+	"alphanumeric": TypeString,
+	"char":         TypeString,
+	"string":       TypeString,
+	"text":         TypeString,
+	"n":            TypeInt,
+	"num":          TypeInt,
+	"numeric":      TypeInt,
+	"number":       TypeInt,
+	"int":          TypeInt,
+	"integer":      TypeInt,
+	"uint":         TypeUint,
+	"unsigned":     TypeUint,
+	"decimal":      TypeFloat,
+	"dec":          TypeFloat,
+	"float":        TypeFloat,
+	"amount":       TypeFloat,
+	"bool":         TypeBool,
+	"boolean":      TypeBool,
+	"flag":         TypeBool,
+	"date":         TypeTime,
+	"time":         TypeTime,
+	"datetime":     TypeTime,
+	"timestamp":    TypeTime,
+	"json":         TypeJSON,
+}
+
+var specDateFormatReplacer = strings.NewReplacer(
+	"YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH24", "15", "HH", "15", "MI", "04", "SS", "05",
+)
+
+// LoadSpecCSV reads a vendor layout spec table in CSV format and converts it into a validated layout.
+// The first row must be a header naming the spec columns, e.g.
+//
+//	Field Name,Start,End,Length,Type,Format
+//	Name,1,16,16,AN,
+//	Birthday,17,24,8,Date,YYYYMMDD
+//
+// Recognized spec columns are the name, start, end (inclusive), length, type, format, align, pad and description.
+// Either end or length is required. Rows without a start follow the previous column.
+func LoadSpecCSV(reader io.Reader, opts SpecOptions) (*Layout, error) {
+	r := csv.NewReader(reader)
+	if opts.Comma != 0 {
+		r.Comma = opts.Comma
+	}
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: empty spec", ErrIncorrectLayout)
+	}
+
+	keys := make([]string, len(rows[0]))
+	for i, header := range rows[0] {
+		keys[i] = specKeys[normalizeSpecKey(header)]
+	}
+	specRows := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		specRow := make(map[string]string, len(row))
+		for i, value := range row {
+			if i < len(keys) && keys[i] != "" {
+				specRow[keys[i]] = strings.TrimSpace(value)
+			}
+		}
+		specRows = append(specRows, specRow)
+	}
+	return buildSpecLayout(specRows, opts)
+}
+
+// LoadSpecJSON reads a vendor layout spec in JSON format, an array of objects with the same attributes
+// as the LoadSpecCSV columns, and converts it into a validated layout:
+//
+//	[
+//	    {"name": "Name", "start": 1, "length": 16, "type": "AN"},
+//	    {"name": "Birthday", "start": 17, "end": 24, "type": "Date", "format": "YYYYMMDD"}
+//	]
+func LoadSpecJSON(reader io.Reader, opts SpecOptions) (*Layout, error) {
+	var items []map[string]any
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	if err := decoder.Decode(&items); err != nil {
+		return nil, err
+	}
+	specRows := make([]map[string]string, 0, len(items))
+	for i, item := range items {
+		specRow := make(map[string]string, len(item))
+		for key, value := range item {
+			k := specKeys[normalizeSpecKey(key)]
+			if k == "" || value == nil {
+				continue
+			}
+			text, err := specJSONValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: spec row %d: %s: %w", ErrIncorrectLayout, i+1, key, err)
+			}
+			specRow[k] = strings.TrimSpace(text)
+		}
+		specRows = append(specRows, specRow)
+	}
+	return buildSpecLayout(specRows, opts)
+}
+
+// specJSONValue returns the text of a JSON spec attribute, numbers are kept as they are written.
+func specJSONValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+func normalizeSpecKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(key)
+}
+
+// specPosition is a column position as it's written in the spec.
+type specPosition struct {
+	start, end, length int
+	hasStart, hasEnd   bool
+}
+
+func buildSpecLayout(rows []map[string]string, opts SpecOptions) (*Layout, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: empty spec", ErrIncorrectLayout)
+	}
+
+	positions := make([]specPosition, len(rows))
+	layout := &Layout{Columns: make([]Column, len(rows))}
+	for i, row := range rows {
+		c := &layout.Columns[i]
+		c.Name = row["name"]
+		if c.Name == "" {
+			return nil, fmt.Errorf("%w: spec row %d has no name", ErrIncorrectLayout, i+1)
+		}
+		var err error
+		if positions[i], err = parseSpecPosition(row); err != nil {
+			return nil, fmt.Errorf("%w: column %s: %w", ErrIncorrectLayout, c.Name, err)
+		}
+		if err = setSpecAttributes(c, row); err != nil {
+			return nil, fmt.Errorf("%w: column %s: %w", ErrIncorrectLayout, c.Name, err)
+		}
+	}
+
+	offset, err := specBaseOffset(positions, opts.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 0
+	for i := range positions {
+		c, p := &layout.Columns[i], &positions[i]
+		c.Start = next
+		if p.hasStart {
+			c.Start = p.start - offset
+		}
+		if p.hasEnd {
+			c.Width = p.end - offset - c.Start + 1
+			if p.length > 0 && p.length != c.Width {
+				return nil, fmt.Errorf("%w: column %s: end %d doesn't match length %d, check the position base",
+					ErrIncorrectLayout, c.Name, p.end, p.length)
+			}
+		} else {
+			c.Width = p.length
+		}
+		next = c.End()
+	}
+
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	if !opts.AllowGaps {
+		if err := checkLayoutGaps(layout); err != nil {
+			return nil, err
+		}
+	}
+	return layout, nil
+}
+
+func parseSpecPosition(row map[string]string) (specPosition, error) {
+	var p specPosition
+	var err error
+	if value := row["start"]; value != "" {
+		if p.start, err = strconv.Atoi(value); err != nil {
+			return p, fmt.Errorf("invalid start: %w", err)
+		}
+		p.hasStart = true
+	}
+	if value := row["end"]; value != "" {
+		if p.end, err = strconv.Atoi(value); err != nil {
+			return p, fmt.Errorf("invalid end: %w", err)
+		}
+		p.hasEnd = true
+	}
+	if value := row["length"]; value != "" {
+		if p.length, err = strconv.Atoi(value); err != nil {
+			return p, fmt.Errorf("invalid length: %w", err)
+		}
+		if p.length <= 0 {
+			return p, fmt.Errorf("non-positive length %d", p.length)
+		}
+	}
+	if !p.hasEnd && p.length == 0 {
+		return p, errors.New("either end or length is required")
+	}
+	if p.hasEnd && !p.hasStart {
+		return p, errors.New("end requires start")
+	}
+	return p, nil
+}
+
+func setSpecAttributes(c *Column, row map[string]string) error {
+	columnType, ok := specTypes[strings.ToLower(row["type"])]
+	if !ok {
+		return fmt.Errorf("unknown type %q", row["type"])
+	}
+	c.Type = columnType
+
+	c.Format = row["format"]
+	c.Description = row["description"]
+	if strings.Contains(c.Format, "YY") || strings.Contains(c.Format, "DD") {
+		c.Format = specDateFormatReplacer.Replace(c.Format)
+	}
+
+	if err := c.Align.UnmarshalText([]byte(row["align"])); err != nil {
+		return err
+	}
+
+	switch pad := row["pad"]; strings.ToLower(pad) {
+	case "", "space", "spaces", "blank":
+	case "zero", "zeros", "zeroes":
+		c.Pad = "0"
+	default:
+		c.Pad = pad
+	}
+	return nil
+}
+
+func specBaseOffset(positions []specPosition, base PositionBase) (int, error) {
+	switch base {
+	case BaseZero:
+		return 0, nil
+	case BaseOne:
+		return 1, nil
+	case BaseAuto:
+		minStart := -1
+		for _, p := range positions {
+			if p.hasStart && (minStart < 0 || p.start < minStart) {
+				minStart = p.start
+			}
+		}
+		if minStart == 1 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unknown position base %d", base)
+}
+
+func checkLayoutGaps(layout *Layout) error {
+	end := 0
+	for _, c := range layout.sortedColumns() {
+		if c.Start > end {
+			return fmt.Errorf("%w: gap of %d characters before column %s", ErrIncorrectLayout, c.Start-end, c.Name)
+		}
+		end = c.End()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package fwencoder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSpecCSV(t *testing.T) {
+	spec := `Field Name, Start, End, Length, Type, Format, Padding, Justify
+Name,1,16,16,AN,,,
+Amount,17,26,10,Decimal,,zeros,right
+Birthday,27,34,8,Date,YYYYMMDD,,
+Flag,,,1,,,,`
+
+	expected := &Layout{Columns: []Column{
+		{Name: "Name", Start: 0, Width: 16, Type: TypeString},
+		{Name: "Amount", Start: 16, Width: 10, Align: AlignRight, Pad: "0", Type: TypeFloat},
+		{Name: "Birthday", Start: 26, Width: 8, Type: TypeTime, Format: "20060102"},
+		{Name: "Flag", Start: 34, Width: 1, Type: TypeString},
+	}}
+
+	layout, err := LoadSpecCSV(strings.NewReader(spec), SpecOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, expected, layout)
+
+	layout, err = LoadSpecCSV(strings.NewReader(strings.ReplaceAll(spec, ",", ";")), SpecOptions{Comma: ';', Base: BaseOne})
+	require.NoError(t, err)
+	assert.Equal(t, expected, layout)
+
+	_, err = LoadSpecCSV(strings.NewReader(spec), SpecOptions{Base: BaseZero})
+	require.EqualError(t, err, "incorrect layout: gap of 1 characters before column Name")
+
+	layout, err = LoadSpecCSV(strings.NewReader("name,start,length\nA,0,5\nB,5,3"), SpecOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []Column{
+		{Name: "A", Start: 0, Width: 5, Type: TypeString},
+		{Name: "B", Start: 5, Width: 3, Type: TypeString},
+	}, layout.Columns)
+}
+
+func TestLoadSpec_BaseAuto(t *testing.T) {
+	tests := map[string][]int{
+		"name,start,length\nB,6,3\nA,1,5": {5, 0}, // the smallest start is 1, rows needn't be sorted
+		"name,start,length\nA,0,5\nB,5,3": {0, 5},
+		"name,start,length\nA,2,5\nB,7,3": {2, 7}, // a spec starting after the first character is 0-based
+		"name,length\nA,5\nB,3":           {0, 5},
+	}
+	for spec, starts := range tests {
+		layout, err := LoadSpecCSV(strings.NewReader(spec), SpecOptions{AllowGaps: true})
+		require.NoError(t, err, spec)
+		for i, start := range starts {
+			assert.Equal(t, start, layout.Columns[i].Start, spec)
+		}
+	}
+
+	layout, err := LoadSpecCSV(strings.NewReader("name,start,length\nA,2,5\nB,7,3"), SpecOptions{Base: BaseOne, AllowGaps: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, layout.Columns[0].Start)
+}
+
+func TestLoadSpecJSON(t *testing.T) {
+	spec := `[
+		{"name": "Name", "start": 1, "length": 16, "type": "AN"},
+		{"field_name": "Birthday", "start": 17, "end": 24, "type": "Date", "format": "YYYYMMDD"}
+	]`
+	layout, err := LoadSpecJSON(strings.NewReader(spec), SpecOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &Layout{Columns: []Column{
+		{Name: "Name", Start: 0, Width: 16, Type: TypeString},
+		{Name: "Birthday", Start: 16, Width: 8, Type: TypeTime, Format: "20060102"},
+	}}, layout)
+
+	// numbers are read as they are written, not as floats
+	spec = `[{"name": "A", "start": 10000001, "length": "12"}]`
+	layout, err = LoadSpecJSON(strings.NewReader(spec), SpecOptions{Base: BaseOne, AllowGaps: true})
+	require.NoError(t, err)
+	assert.Equal(t, []Column{{Name: "A", Start: 10000000, Width: 12, Type: TypeString}}, layout.Columns)
+
+	_, err = LoadSpecJSON(strings.NewReader(`[{"name": "A", "start": 1.5, "length": 2}]`), SpecOptions{})
+	require.EqualError(t, err, `incorrect layout: column A: invalid start: strconv.Atoi: parsing "1.5": invalid syntax`)
+	_, err = LoadSpecJSON(strings.NewReader(`[{"name": "A", "start": [1], "length": 2}]`), SpecOptions{})
+	require.EqualError(t, err, "incorrect layout: spec row 1: start: unsupported value [1]")
+}
+
+func TestLoadSpec_Validation(t *testing.T) {
+	tests := map[string]string{
+		"name,start,length\nA,1,5\nB,4,3":              "incorrect layout: columns A and B overlap",
+		"name,start,length\nA,1,5\nB,8,3":              "incorrect layout: gap of 2 characters before column B",
+		"name,start,length\nA,1,0":                     "incorrect layout: column A: non-positive length 0",
+		"name,start,end,length\nA,0,10,10\nB,10,20,10": "incorrect layout: column A: end 10 doesn't match length 10, check the position base",
+		"name,start,end\nA,1,0":                        "incorrect layout: column A has non-positive width 0",
+		"name,start\nA,1":                              "incorrect layout: column A: either end or length is required",
+		"name,end\nA,1":                                "incorrect layout: column A: end requires start",
+		"name,start,length\nA,x,1":                     `incorrect layout: column A: invalid start: strconv.Atoi: parsing "x": invalid syntax`,
+		"name,start,length,type\nA,1,1,blob":           `incorrect layout: column A: unknown type "blob"`,
+		"name,start,length\n,1,1":                      "incorrect layout: spec row 1 has no name",
+		"name,start,length":                            "incorrect layout: empty spec",
+	}
+	for spec, expected := range tests {
+		_, err := LoadSpecCSV(strings.NewReader(spec), SpecOptions{})
+		require.EqualError(t, err, expected, spec)
+		require.ErrorIs(t, err, ErrIncorrectLayout)
+	}
+
+	layout, err := LoadSpecCSV(strings.NewReader("name,start,length\nA,1,5\nB,8,3"), SpecOptions{AllowGaps: true})
+	require.NoError(t, err)
+	assert.Equal(t, 10, layout.LineLength())
+
+	_, err = LoadSpecCSV(strings.NewReader("name,start,length\nA,0,5"), SpecOptions{Base: BaseOne})
+	require.EqualError(t, err, "incorrect layout: column A has negative start -1")
+}
@@ -0,0 +1,127 @@
+package fwencoder
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DocFormat defines the output format of WriteLayoutDoc.
+type DocFormat int
+
+const (
+	// DocMarkdown renders a Markdown table.
+	DocMarkdown DocFormat = iota
+	// DocHTML renders an HTML table.
+	DocHTML
+	// DocCSV renders a CSV table which can be loaded back with LoadSpecCSV. Set the same Base to load
+	// the documentation of a layout which doesn't start at the first character and AllowGaps if it has gaps.
+	DocCSV
+)
+
+// DocOptions configures WriteLayoutDoc.
+type DocOptions struct {
+	// Format is the output format, DocMarkdown by default
+	Format DocFormat
+	// Base is the position base of the start and end positions. BaseAuto and BaseOne produce 1-based positions.
+	Base PositionBase
+}
+
+var docHeader = []string{"Name", "Start", "End", "Width", "Type", "Format", "Align", "Pad", "Description"}
+
+// WriteLayoutDoc renders the record layout documentation of v as a spec table with column name, start and end
+// positions, width, type, format, alignment, padding and description. End positions are inclusive.
+// v is either a Layout or a struct accepted by LayoutOf, descriptions of struct fields are read from
+// the `description` tag.
+func WriteLayoutDoc(writer io.Writer, v any, opts DocOptions) error {
+	var layout *Layout
+	switch l := v.(type) {
+	case *Layout:
+		layout = l
+	case Layout:
+		layout = &l
+	default:
+		var err error
+		if layout, err = LayoutOf(v); err != nil {
+			return err
+		}
+	}
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+
+	offset := 1
+	if opts.Base == BaseZero {
+		offset = 0
+	}
+	rows := make([][]string, len(layout.Columns))
+	for i := range layout.Columns {
+		c := &layout.Columns[i]
+		align, err := c.Align.MarshalText()
+		if err != nil {
+			return err
+		}
+		rows[i] = []string{
+			c.Name,
+			strconv.Itoa(c.Start + offset),
+			strconv.Itoa(c.End() - 1 + offset),
+			strconv.Itoa(c.Width),
+			string(c.Type),
+			c.Format,
+			string(align),
+			c.Pad,
+			c.Description,
+		}
+	}
+
+	switch opts.Format {
+	case DocMarkdown:
+		return writeMarkdownDoc(writer, rows)
+	case DocHTML:
+		return writeHTMLDoc(writer, rows)
+	case DocCSV:
+		w := csv.NewWriter(writer)
+		if err := w.Write(docHeader); err != nil {
+			return err
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+	return fmt.Errorf("unknown doc format %d", opts.Format)
+}
+
+func writeMarkdownDoc(writer io.Writer, rows [][]string) error {
+	records := make([]Record, len(rows))
+	for i, row := range rows {
+		records[i] = Record{header: newRecordHeader(docHeader), values: row}
+	}
+	if err := MarshalWriter(writer, &records, WithColumns(docHeader...), WithTableStyle(StyleMarkdown)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+func writeHTMLDoc(writer io.Writer, rows [][]string) error {
+	var sb strings.Builder
+	sb.WriteString("<table>\n  <thead>\n    <tr>")
+	for _, h := range docHeader {
+		sb.WriteString("<th>" + html.EscapeString(h) + "</th>")
+	}
+	sb.WriteString("</tr>\n  </thead>\n  <tbody>\n")
+	for _, row := range rows {
+		sb.WriteString("    <tr>")
+		for _, value := range row {
+			sb.WriteString("<td>" + html.EscapeString(value) + "</td>")
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("  </tbody>\n</table>\n")
+	_, err := io.WriteString(writer, sb.String())
+	return err
+}
@@ -0,0 +1,67 @@
+package fwencoder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type DocPerson struct {
+	Name    string  `fw:",width=8" description:"Full name | nickname"`
+	Balance float64 `fw:",width=10,align=right,pad=0" description:"Balance in <USD>"`
+}
+
+func TestWriteLayoutDoc(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteLayoutDoc(&buf, DocPerson{}, DocOptions{}))
+	assert.Equal(t, ""+
+		"| Name    | Start | End | Width | Type   | Format | Align | Pad | Description           |\n"+
+		"| ------- | ----- | --- | ----- | ------ | ------ | ----- | --- | --------------------- |\n"+
+		"| Name    | 1     | 8   | 8     | string |        | left  |     | Full name \\| nickname |\n"+
+		"| Balance | 9     | 18  | 10    | float  |        | right | 0   | Balance in <USD>      |\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteLayoutDoc(&buf, &DocPerson{}, DocOptions{Format: DocHTML, Base: BaseZero}))
+	assert.Equal(t, "<table>\n  <thead>\n"+
+		"    <tr><th>Name</th><th>Start</th><th>End</th><th>Width</th><th>Type</th><th>Format</th><th>Align</th>"+
+		"<th>Pad</th><th>Description</th></tr>\n"+
+		"  </thead>\n  <tbody>\n"+
+		"    <tr><td>Name</td><td>0</td><td>7</td><td>8</td><td>string</td><td></td><td>left</td><td></td>"+
+		"<td>Full name | nickname</td></tr>\n"+
+		"    <tr><td>Balance</td><td>8</td><td>17</td><td>10</td><td>float</td><td></td><td>right</td><td>0</td>"+
+		"<td>Balance in &lt;USD&gt;</td></tr>\n"+
+		"  </tbody>\n</table>\n", buf.String())
+
+	require.ErrorIs(t, WriteLayoutDoc(&buf, 1, DocOptions{}), ErrIncorrectInputValue)
+	require.EqualError(t, WriteLayoutDoc(&buf, DocPerson{}, DocOptions{Format: 10}), "unknown doc format 10")
+}
+
+func TestWriteLayoutDoc_CSVRoundTrip(t *testing.T) {
+	layout, err := LayoutOf(DocPerson{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteLayoutDoc(&buf, layout, DocOptions{Format: DocCSV}))
+	assert.Equal(t, "Name,Start,End,Width,Type,Format,Align,Pad,Description\n"+
+		"Name,1,8,8,string,,left,,Full name | nickname\n"+
+		"Balance,9,18,10,float,,right,0,Balance in <USD>\n", buf.String())
+
+	loaded, err := LoadSpecCSV(&buf, SpecOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, layout, loaded)
+
+	// a layout with gaps which doesn't start at the first character
+	layout = &Layout{Columns: []Column{
+		{Name: "Code", Start: 2, Width: 4, Type: TypeString},
+		{Name: "Amount", Start: 10, Width: 6, Align: AlignRight, Pad: "0", Type: TypeInt},
+	}}
+	for _, base := range []PositionBase{BaseZero, BaseOne} {
+		buf.Reset()
+		require.NoError(t, WriteLayoutDoc(&buf, layout, DocOptions{Format: DocCSV, Base: base}))
+		loaded, err = LoadSpecCSV(&buf, SpecOptions{Base: base, AllowGaps: true})
+		require.NoError(t, err)
+		assert.Equal(t, layout, loaded)
+	}
+}
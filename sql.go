@@ -0,0 +1,119 @@
+package fwencoder
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isSQLNullType reports whether t is one of the nullable types of database/sql: sql.NullString, sql.NullInt64,
+// sql.NullTime, sql.Null[T] etc. They hold the value in the first field and its validity in the Valid field.
+// The cells of these types are the cells of their values, empty cells and null tokens are invalid values.
+func isSQLNullType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null") &&
+		t.NumField() == 2 && t.Field(1).Name == "Valid" && t.Field(1).Type.Kind() == reflect.Bool
+}
+
+// isScannerType reports whether the pointer to t implements sql.Scanner, the cells of such types are scanned
+// from strings.
+func isScannerType(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(scannerType)
+}
+
+// newSQLSetter returns the setter of sql null types and sql.Scanner implementations, nil for other types.
+// Sql null values are parsed according to the tags of the struct field, e.g. the time format, and marked valid.
+func newSQLSetter(structField *reflect.StructField, t reflect.Type) typedSetter {
+	switch {
+	case isSQLNullType(t):
+		valueField := reflect.StructField{Name: structField.Name, Type: t.Field(0).Type, Tag: structField.Tag}
+		setValue := newFieldSetter(&valueField, nil, false)
+		return func(field reflect.Value, _ *reflect.StructField, rawValue []byte, isPointer bool) error {
+			if isPointer {
+				field.Set(reflect.New(t))
+				field = field.Elem()
+			}
+			if err := setValue(field.Field(0), rawValue); err != nil {
+				return err
+			}
+			field.Field(1).SetBool(true)
+			return nil
+		}
+	case isScannerType(t):
+		return setScannerFieldValue
+	}
+	return nil
+}
+
+func setScannerFieldValue(field reflect.Value, structField *reflect.StructField, rawValue []byte, isPointer bool) error {
+	if isPointer {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if err := field.Addr().Interface().(sql.Scanner).Scan(string(rawValue)); err != nil {
+		return newCastingError(err, string(rawValue), structField)
+	}
+	return nil
+}
+
+// hasSQLValue reports whether the values of a field of type t may be sql null types or driver.Valuer implementations.
+func hasSQLValue(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Interface || isSQLNullType(t) ||
+		t.Implements(valuerType) || reflect.PointerTo(t).Implements(valuerType)
+}
+
+// valuerOf returns the value as driver.Valuer if it or its pointer implements it.
+func valuerOf(value reflect.Value) (driver.Valuer, bool) {
+	if value.Type().Implements(valuerType) {
+		return value.Interface().(driver.Valuer), true
+	}
+	if value.CanAddr() && reflect.PointerTo(value.Type()).Implements(valuerType) {
+		return value.Addr().Interface().(driver.Valuer), true
+	}
+	return nil, false
+}
+
+// sqlValueOf unwraps the sql null type or driver.Valuer value, invalid values and nil are returned
+// as the zero reflect.Value. It reports false if the value is neither of them.
+func sqlValueOf(value reflect.Value) (reflect.Value, bool, error) {
+	if isSQLNullType(value.Type()) {
+		if !value.Field(1).Bool() {
+			return reflect.Value{}, true, nil
+		}
+		return value.Field(0), true, nil
+	}
+	valuer, ok := valuerOf(value)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	v, err := valuer.Value()
+	if err != nil {
+		return reflect.Value{}, true, err
+	}
+	if b, ok := v.([]byte); ok {
+		return reflect.ValueOf(string(b)), true, nil
+	}
+	return reflect.ValueOf(v), true, nil
+}
+
+// appendSQLValue appends the text of the sql null type or driver.Valuer value, invalid values and nil are null.
+// It reports false if the value is neither of them.
+func appendSQLValue(buf []byte, value reflect.Value, field *fieldPlan) ([]byte, bool, error) {
+	v, ok, err := sqlValueOf(value)
+	if !ok || err != nil {
+		return buf, ok, err
+	}
+	if !v.IsValid() {
+		return appendNull(buf, field), true, nil
+	}
+	buf, err = appendValue(buf, v, field)
+	return buf, true, err
+}
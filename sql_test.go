@@ -0,0 +1,74 @@
+package fwencoder
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMoney implements sql.Scanner and driver.Valuer with a decimal text representation.
+type testMoney struct {
+	cents int64
+}
+
+func (m testMoney) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100), nil
+}
+
+func (m *testMoney) Scan(src any) error {
+	var units, cents int64
+	if _, err := fmt.Sscanf(fmt.Sprint(src), "%d.%d", &units, &cents); err != nil {
+		return err
+	}
+	m.cents = units*100 + cents
+	return nil
+}
+
+type sqlRow struct {
+	Name   sql.NullString
+	Age    sql.NullInt64     `fw:",null=NULL"`
+	Born   sql.NullTime      `format:"2006-01-02"`
+	Score  sql.Null[float64] `fw:",prec=1"`
+	Active *sql.NullBool
+	Price  testMoney
+}
+
+func TestSQLTypes(t *testing.T) {
+	rows := []sqlRow{
+		{
+			Name:   sql.NullString{String: "John", Valid: true},
+			Age:    sql.NullInt64{Int64: 42, Valid: true},
+			Born:   sql.NullTime{Time: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), Valid: true},
+			Score:  sql.Null[float64]{V: 9.5, Valid: true},
+			Active: &sql.NullBool{Bool: true, Valid: true},
+			Price:  testMoney{cents: 1234},
+		},
+		{},
+	}
+
+	b, err := Marshal(&rows)
+	require.NoError(t, err)
+	assert.Equal(t, "Name Age  Born       Score Active Price\n"+
+		"John 42   2000-01-02 9.5   true   12.34\n"+
+		"     NULL                         0.00 ", string(b))
+
+	var obtained []sqlRow
+	require.NoError(t, Unmarshal(b, &obtained))
+	assert.Equal(t, rows, obtained)
+
+	err = Unmarshal([]byte("Age\nx  "), &obtained)
+	require.ErrorContains(t, err, `filed casting "x" to "Age:int64"`)
+	err = Unmarshal([]byte("Price\nfree "), &obtained)
+	require.ErrorContains(t, err, `filed casting "free" to "Price:fwencoder.testMoney"`)
+
+	layout, err := LayoutOf(&[]struct {
+		Age sql.NullInt64 `fw:",width=3"`
+	}{})
+	require.NoError(t, err)
+	assert.Equal(t, TypeInt, layout.Columns[0].Type)
+}
@@ -0,0 +1,108 @@
+package fwencoder
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// TableStyle defines how MarshalWriter lays out columns and rows.
+type TableStyle int
+
+const (
+	// StylePlain renders fixed width columns separated by a single space. This is the default style.
+	StylePlain TableStyle = iota
+	// StyleASCII renders a table bordered with ASCII characters (+, - and |).
+	StyleASCII
+	// StyleUnicode renders a table bordered with Unicode box-drawing characters.
+	StyleUnicode
+	// StyleMarkdown renders a Markdown pipe table.
+	StyleMarkdown
+)
+
+// markdownMinWidth is the minimum column width required by the Markdown delimiter row.
+const markdownMinWidth = 3
+
+// markdownEscaper escapes the text of Markdown table cells: pipes would start new cells and line breaks new rows.
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// escapeMarkdown escapes buf[start:] with markdownEscaper.
+func escapeMarkdown(buf []byte, start int) []byte {
+	if !bytes.ContainsAny(buf[start:], "|\r\n") {
+		return buf
+	}
+	return append(buf[:start], markdownEscaper.Replace(string(buf[start:]))...)
+}
+
+// borderLine describes a horizontal line of a table: left + fill*width + cross + fill*width + ... + right.
+type borderLine struct {
+	left  string
+	fill  string
+	cross string
+	right string
+}
+
+type tableBorder struct {
+	top       *borderLine
+	headerSep *borderLine
+	bottom    *borderLine
+	rowLeft   string
+	rowSep    string
+	rowRight  string
+}
+
+var tableBorders = map[TableStyle]*tableBorder{
+	StylePlain: {
+		rowSep: " ",
+	},
+	StyleASCII: {
+		top:       &borderLine{left: "+-", fill: "-", cross: "-+-", right: "-+"},
+		headerSep: &borderLine{left: "+-", fill: "-", cross: "-+-", right: "-+"},
+		bottom:    &borderLine{left: "+-", fill: "-", cross: "-+-", right: "-+"},
+		rowLeft:   "| ",
+		rowSep:    " | ",
+		rowRight:  " |",
+	},
+	StyleUnicode: {
+		top:       &borderLine{left: "┌─", fill: "─", cross: "─┬─", right: "─┐"},
+		headerSep: &borderLine{left: "├─", fill: "─", cross: "─┼─", right: "─┤"},
+		bottom:    &borderLine{left: "└─", fill: "─", cross: "─┴─", right: "─┘"},
+		rowLeft:   "│ ",
+		rowSep:    " │ ",
+		rowRight:  " │",
+	},
+	StyleMarkdown: {
+		headerSep: &borderLine{left: "| ", fill: "-", cross: " | ", right: " |"},
+		rowLeft:   "| ",
+		rowSep:    " | ",
+		rowRight:  " |",
+	},
+}
+
+// ErrUnknownTableStyle represents unsupported TableStyle passed to the encoder
+var ErrUnknownTableStyle = errors.New("unknown table style")
+
+func getTableBorder(style TableStyle) (*tableBorder, error) {
+	border, ok := tableBorders[style]
+	if !ok {
+		return nil, ErrUnknownTableStyle
+	}
+	return border, nil
+}
+
+func writeBorderLine(lines *lineWriter, border *borderLine, columns []tableColumn) error {
+	if border == nil {
+		return nil
+	}
+	line := []byte(border.left)
+	for i, c := range columns {
+		for range c.width {
+			line = append(line, border.fill...)
+		}
+		if i != len(columns)-1 {
+			line = append(line, border.cross...)
+		}
+	}
+	line = append(line, border.right...)
+	return lines.writeLine(line)
+}
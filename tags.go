@@ -0,0 +1,121 @@
+package fwencoder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const fwTagName = "fw"
+
+// fwTag holds the options of the `fw` struct tag, e.g. `fw:"Amount,start=10,width=12,align=right,pad=0"`.
+// The first element is the column name, the rest are comma separated key=value pairs or flags.
+// The name can be omitted if the tag has only options, e.g. `fw:"prec=2"`.
+type fwTag struct {
+	name      string
+	start     int
+	hasStart  bool
+	width     int
+	align     Alignment
+	pad       rune
+	overflow  OverflowPolicy
+	omitEmpty bool
+	aliases   []string // other accepted header names
+	required  bool
+	extra     bool     // the field receives the columns unknown to the struct
+	nulls     []string // null tokens, the first one is written for nil pointers
+	zeroNull  bool     // cells of zeros only are null
+	float     byte     // float format of strconv.FormatFloat: 'g' by default, 'f' with the fixed flag, 'e' with the exp flag
+	prec      int      // float precision, -1 for the shortest representation
+}
+
+func splitFwTag(field *reflect.StructField) (name string, opts []string) {
+	tag, ok := field.Tag.Lookup(fwTagName)
+	if !ok {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	if strings.Contains(parts[0], "=") {
+		return "", parts
+	}
+	return parts[0], parts[1:]
+}
+
+func parseFwTag(field *reflect.StructField) (*fwTag, error) {
+	name, opts := splitFwTag(field)
+	tag := &fwTag{name: name, pad: ' ', float: 'g', prec: -1}
+	for _, opt := range opts {
+		key, value, _ := strings.Cut(opt, "=")
+		if err := tag.set(key, value); err != nil {
+			return nil, fmt.Errorf("field %s: invalid %s tag option %q: %w", field.Name, fwTagName, opt, err)
+		}
+	}
+	// the precision alone sets the number of decimal places
+	if tag.prec >= 0 && tag.float == 'g' {
+		tag.float = 'f'
+	}
+	return tag, nil
+}
+
+func (t *fwTag) set(key, value string) error {
+	var err error
+	switch key {
+	case "start":
+		if t.start, err = strconv.Atoi(value); err == nil && t.start < 0 {
+			return fmt.Errorf("negative start")
+		}
+		t.hasStart = true
+	case "width":
+		if t.width, err = strconv.Atoi(value); err == nil && t.width <= 0 {
+			return fmt.Errorf("non-positive width")
+		}
+	case "align":
+		err = t.align.UnmarshalText([]byte(value))
+	case "pad":
+		if utf8.RuneCountInString(value) != 1 {
+			return fmt.Errorf("pad must be a single character")
+		}
+		t.pad, _ = utf8.DecodeRuneInString(value)
+	case "overflow":
+		err = t.overflow.UnmarshalText([]byte(value))
+	case "prec":
+		if t.prec, err = strconv.Atoi(value); err == nil && t.prec < 0 {
+			return fmt.Errorf("negative precision")
+		}
+	case "alias":
+		t.aliases = append(t.aliases, strings.Split(value, "|")...)
+	case "null":
+		t.nulls = append(t.nulls, strings.Split(value, "|")...)
+	case "nullzeros":
+		t.zeroNull = true
+	case "extra":
+		t.extra = true
+	case "required":
+		t.required = true
+	case "omitempty":
+		t.omitEmpty = true
+	case "fixed":
+		t.float = 'f'
+	case "exp":
+		t.float = 'e'
+	default:
+		return fmt.Errorf("unknown option")
+	}
+	return err
+}
+
+// isNull reports whether the trimmed cell is one of the null tokens of the tag.
+func (t *fwTag) isNull(value []byte) bool {
+	if t == nil {
+		return false
+	}
+	for _, token := range t.nulls {
+		if string(value) == token {
+			return true
+		}
+	}
+	return t.zeroNull && len(value) > 0 && len(bytes.Trim(value, "0")) == 0
+}
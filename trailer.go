@@ -0,0 +1,268 @@
+package fwencoder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrTrailerNotFound is returned by the decoder when the input has no trailer line
+	ErrTrailerNotFound = errors.New("trailer line not found")
+	// ErrTrailerMismatch is returned by the decoder when the trailer values don't match the decoded records
+	ErrTrailerMismatch = errors.New("trailer doesn't match data")
+)
+
+// TrailerFieldKind defines the value stored in a trailer field.
+type TrailerFieldKind int
+
+const (
+	// TrailerCount is the number of data records.
+	TrailerCount TrailerFieldKind = iota
+	// TrailerSum is the sum of a numeric column.
+	TrailerSum
+)
+
+// TrailerField describes a numeric field of the trailer line.
+type TrailerField struct {
+	// Kind defines the value of the field
+	Kind TrailerFieldKind
+	// Column is the name of the summed column, used by TrailerSum only
+	Column string
+	// Start is the 0-based position of the field in the trailer line
+	Start int
+	// Width is the number of characters occupied by the field. Values are zero padded to this width.
+	Width int
+	// Decimals is the number of implied decimal places of a sum, e.g. 2 for amounts written in cents
+	Decimals int
+}
+
+// Trailer describes a trailer (footer) line carrying the record count and control totals, e.g.
+//
+//	TRL 000001523 0000012345678
+//
+// is described by
+//
+//	fwencoder.Trailer{
+//	    Prefix: "TRL",
+//	    Fields: []fwencoder.TrailerField{
+//	        {Kind: fwencoder.TrailerCount, Start: 4, Width: 9},
+//	        {Kind: fwencoder.TrailerSum, Column: "Amount", Start: 14, Width: 13, Decimals: 2},
+//	    },
+//	}
+type Trailer struct {
+	// Prefix identifies the trailer line, it's written at the beginning of the line. The decoder takes the last
+	// non-blank line for the trailer if it starts with the prefix, so data lines may start with it too.
+	Prefix string
+	// Fields is the list of trailer fields
+	Fields []TrailerField
+}
+
+func (t *Trailer) validate() error {
+	if t.Prefix == "" {
+		return errors.New("trailer prefix is empty")
+	}
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		if f.Start < len([]rune(t.Prefix)) || f.Width <= 0 {
+			return fmt.Errorf("trailer field %d has invalid position %d:%d", i, f.Start, f.Start+f.Width)
+		}
+		if f.Kind == TrailerSum && f.Column == "" {
+			return fmt.Errorf("trailer field %d has no column", i)
+		}
+	}
+	order := make([]int, len(t.Fields))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return t.Fields[a].Start - t.Fields[b].Start })
+	for i := 1; i < len(order); i++ {
+		prev, next := &t.Fields[order[i-1]], &t.Fields[order[i]]
+		if prev.Start+prev.Width > next.Start {
+			return fmt.Errorf("trailer fields %d and %d overlap", order[i-1], order[i])
+		}
+	}
+	return nil
+}
+
+func (t *Trailer) isTrailerLine(line []byte) bool {
+	return hasPrefix(line, t.Prefix)
+}
+
+// trailerCandidate holds back a line starting with the trailer prefix and the blank lines following it.
+// The line is the trailer if it's the last non-blank line, otherwise the held lines are data.
+type trailerCandidate struct {
+	lines    [][]byte
+	lineNums []int
+}
+
+func (c *trailerCandidate) held() bool {
+	return len(c.lines) > 0
+}
+
+func (c *trailerCandidate) hold(line []byte, lineNum int) {
+	c.lines = append(c.lines, bytes.Clone(line))
+	c.lineNums = append(c.lineNums, lineNum)
+}
+
+// release passes the held lines to appendLine as data lines.
+func (c *trailerCandidate) release(appendLine func(line []byte, lineNum int) error) error {
+	for i, line := range c.lines {
+		if err := appendLine(line, c.lineNums[i]); err != nil {
+			return err
+		}
+	}
+	c.lines, c.lineNums = c.lines[:0], c.lineNums[:0]
+	return nil
+}
+
+// trailerSource provides the records the trailer values are calculated from.
+type trailerSource interface {
+	recordCount() int
+	// sumColumn sums up numeric values of the column, scaling them by 10^decimals
+	sumColumn(column string, decimals int) (int64, error)
+}
+
+// computeValues calculates the trailer field values for the records.
+func (t *Trailer) computeValues(source trailerSource) ([]int64, error) {
+	values := make([]int64, len(t.Fields))
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		if f.Kind == TrailerCount {
+			values[i] = int64(source.recordCount())
+			continue
+		}
+		sum, err := source.sumColumn(f.Column, f.Decimals)
+		if err != nil {
+			return nil, fmt.Errorf("trailer column %s: %w", f.Column, err)
+		}
+		values[i] = sum
+	}
+	return values, nil
+}
+
+func (t *Trailer) verify(line string, source trailerSource) error {
+	expected, err := t.computeValues(source)
+	if err != nil {
+		return err
+	}
+	lineRunes := []rune(line)
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		end := f.Start + f.Width
+		if end > len(lineRunes) {
+			return fmt.Errorf("%w: trailer line is too short for field %s", ErrTrailerMismatch, f.name())
+		}
+		rawValue := strings.TrimSpace(string(lineRunes[f.Start:end]))
+		value, err := strconv.ParseInt(rawValue, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: can't parse %s %q: %w", ErrTrailerMismatch, f.name(), rawValue, err)
+		}
+		if value != expected[i] {
+			return fmt.Errorf("%w: %s is %d, expected %d", ErrTrailerMismatch, f.name(), value, expected[i])
+		}
+	}
+	return nil
+}
+
+func (t *Trailer) render(source trailerSource) (string, error) {
+	values, err := t.computeValues(source)
+	if err != nil {
+		return "", err
+	}
+	lineRunes := []rune(t.Prefix)
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		rawValue := formatZeroPadded(values[i], f.Width)
+		if len(rawValue) > f.Width {
+			return "", fmt.Errorf("trailer %s %s doesn't fit in %d characters", f.name(), rawValue, f.Width)
+		}
+		for len(lineRunes) < f.Start+f.Width {
+			lineRunes = append(lineRunes, ' ')
+		}
+		copy(lineRunes[f.Start:], []rune(rawValue))
+	}
+	return string(lineRunes), nil
+}
+
+func (f *TrailerField) name() string {
+	if f.Kind == TrailerCount {
+		return "record count"
+	}
+	return "sum of " + f.Column
+}
+
+// formatZeroPadded formats value padded with zeros up to width, keeping the sign in front of the zeros.
+func formatZeroPadded(value int64, width int) string {
+	return fmt.Sprintf("%0*d", width, value)
+}
+
+// sumCells sums up numeric values of the column, scaling them by 10^decimals.
+func sumCells(source encodeSource, column string, decimals int) (int64, error) {
+	columnIndex := slices.Index(source.columnNames(), column)
+	if columnIndex < 0 {
+		return 0, ErrUnknownColumn
+	}
+	scale := math.Pow10(decimals)
+	var sum int64
+	for i := range source.recordCount() {
+		value, _, err := source.cell(i, columnIndex)
+		if err != nil {
+			return 0, err
+		}
+		v, err := scaleValue(value, scale)
+		if err != nil {
+			return 0, err
+		}
+		sum += v
+	}
+	return sum, nil
+}
+
+// scaleValue converts a numeric value or a string with a number to an integer multiplied by scale.
+// Sql null types and driver.Valuer values are unwrapped, invalid values count as 0.
+func scaleValue(value reflect.Value, scale float64) (int64, error) {
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Invalid, reflect.Ptr:
+		return 0, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() * int64(scale), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()) * int64(scale), nil //nolint:gosec // control totals are signed
+	case reflect.Float32, reflect.Float64:
+		return int64(math.Round(value.Float() * scale)), nil
+	case reflect.String:
+		rawValue := strings.TrimSpace(value.String())
+		if rawValue == "" {
+			return 0, nil
+		}
+		if i, err := strconv.ParseInt(rawValue, 10, 64); err == nil {
+			return i * int64(scale), nil
+		}
+		f, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return 0, err
+		}
+		return int64(math.Round(f * scale)), nil
+	default:
+		if v, ok, err := sqlValueOf(value); ok {
+			if err != nil {
+				return 0, err
+			}
+			return scaleValue(v, scale)
+		}
+		return 0, fmt.Errorf("can't sum values of type %v", value.Type())
+	}
+}
@@ -0,0 +1,129 @@
+package fwencoder
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Payment struct {
+	ID     int
+	Amount float64
+}
+
+var paymentTrailer = Trailer{
+	Prefix: "TRL",
+	Fields: []TrailerField{
+		{Kind: TrailerCount, Start: 4, Width: 9},
+		{Kind: TrailerSum, Column: "Amount", Start: 14, Width: 13, Decimals: 2},
+	},
+}
+
+func TestMarshal_Trailer(t *testing.T) {
+	payments := []Payment{{ID: 1, Amount: 100.5}, {ID: 2, Amount: 23.12}}
+
+	b, err := Marshal(&payments, WithTrailer(paymentTrailer))
+	require.NoError(t, err)
+	assert.Equal(t, "ID Amount\n1  100.5 \n2  23.12 \nTRL 000000002 0000000012362", string(b))
+
+	var obtained []Payment
+	require.NoError(t, Unmarshal(b, &obtained, WithTrailer(paymentTrailer)))
+	assert.Equal(t, payments, obtained)
+
+	b, err = Marshal(&[]Payment{}, WithTrailer(paymentTrailer))
+	require.NoError(t, err)
+	assert.Equal(t, "ID Amount\nTRL 000000000 0000000000000", string(b))
+
+	_, err = Marshal(&[]Payment{{Amount: 1e12}}, WithTrailer(paymentTrailer))
+	require.EqualError(t, err, "trailer sum of Amount 100000000000000 doesn't fit in 13 characters")
+}
+
+func TestMarshal_TrailerSQLNull(t *testing.T) {
+	type NullPayment struct {
+		ID     int
+		Amount sql.NullFloat64
+	}
+	payments := []NullPayment{
+		{ID: 1, Amount: sql.NullFloat64{Float64: 100.5, Valid: true}},
+		{ID: 2},
+		{ID: 3, Amount: sql.NullFloat64{Float64: 23.12, Valid: true}},
+	}
+
+	b, err := Marshal(&payments, WithTrailer(paymentTrailer))
+	require.NoError(t, err)
+	assert.Equal(t, "ID Amount\n1  100.5 \n2        \n3  23.12 \nTRL 000000003 0000000012362", string(b))
+
+	var obtained []NullPayment
+	require.NoError(t, Unmarshal(b, &obtained, WithTrailer(paymentTrailer)))
+	assert.Equal(t, payments, obtained)
+}
+
+func TestUnmarshal_Trailer(t *testing.T) {
+	tests := []struct {
+		data  string
+		error string
+		is    error
+	}{
+		{
+			data:  "ID Amount\n1  100.5 \nTRL 000000002 0000000010050",
+			error: "error in line 3: trailer doesn't match data: record count is 2, expected 1",
+			is:    ErrTrailerMismatch,
+		},
+		{
+			data:  "ID Amount\n1  100.5 \nTRL 000000001 0000000010051",
+			error: "error in line 3: trailer doesn't match data: sum of Amount is 10051, expected 10050",
+			is:    ErrTrailerMismatch,
+		},
+		{
+			data:  "ID Amount\n1  100.5 \nTRL 000000001",
+			error: "error in line 3: trailer doesn't match data: trailer line is too short for field sum of Amount",
+			is:    ErrTrailerMismatch,
+		},
+		{
+			data:  "ID Amount\n1  100.5 ",
+			error: "trailer line not found",
+			is:    ErrTrailerNotFound,
+		},
+		{
+			// only the last non-blank line is the trailer
+			data:  "ID Amount\n1  100.5 \nTRL 000000001 0000000010050\n2  1     ",
+			error: "wrong data length in line 3: expected 9 characters, got 27",
+		},
+	}
+
+	for _, tt := range tests {
+		var obtained []Payment
+		err := Unmarshal([]byte(tt.data), &obtained, WithTrailer(paymentTrailer))
+		require.EqualError(t, err, tt.error)
+		if tt.is != nil {
+			require.ErrorIs(t, err, tt.is)
+		}
+	}
+
+	var obtained []Payment
+	err := Unmarshal([]byte("ID\n1 "), &obtained, WithTrailer(Trailer{}))
+	require.EqualError(t, err, "trailer prefix is empty")
+
+	err = Unmarshal([]byte("ID\n1 "), &obtained, WithTrailer(Trailer{Prefix: "TRL", Fields: []TrailerField{{Start: 1, Width: 3}}}))
+	require.EqualError(t, err, "trailer field 0 has invalid position 1:4")
+
+	overlapping := Trailer{Prefix: "TRL", Fields: []TrailerField{{Start: 10, Width: 5}, {Start: 4, Width: 7}}}
+	err = Unmarshal([]byte("ID\n1 "), &obtained, WithTrailer(overlapping))
+	require.EqualError(t, err, "trailer fields 1 and 0 overlap")
+	_, err = Marshal(&obtained, WithTrailer(overlapping))
+	require.EqualError(t, err, "trailer fields 1 and 0 overlap")
+}
+
+func TestUnmarshal_TrailerPrefixInData(t *testing.T) {
+	type Item struct {
+		Code   string
+		Amount float64
+	}
+	data := "Code Amount\nTRLX 1     \nA    2     \nTRL 000000002 0000000000300\n   \n"
+
+	var obtained []Item
+	require.NoError(t, Unmarshal([]byte(data), &obtained, WithTrailer(paymentTrailer)))
+	assert.Equal(t, []Item{{Code: "TRLX", Amount: 1}, {Code: "A", Amount: 2}}, obtained)
+}